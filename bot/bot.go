@@ -6,8 +6,12 @@ import (
 	"syscall"
 
 	"github.com/Noxdew/Knights-Of-Discord/config"
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/dispatcher"
 	"github.com/Noxdew/Knights-Of-Discord/handlers"
 	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/scheduler"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -22,13 +26,26 @@ func Start() {
 		logger.Log.Panic(err)
 	}
 
-	// Add event handlers
-	s.AddHandler(handlers.ReadyHandler)
-	s.AddHandler(handlers.ServerJoinHandler)
-	s.AddHandler(handlers.MessageReceiveHandler)
-	s.AddHandler(handlers.ReactionAddHandler)
-	s.AddHandler(handlers.RoleEditHandler)
-	s.AddHandler(handlers.ChannelEditHandler)
+	// Connect to the database
+	store, err := db.NewMongoStore()
+	if err != nil {
+		logger.Log.Panic(err)
+	}
+
+	// Load the game definition before any event is handled, as the queued handlers read it concurrently
+	err = structure.DefaultServer.BuildServer()
+	if err != nil {
+		logger.Log.Panic(err)
+	}
+
+	// Add event handlers, queued per Guild by the dispatcher.
+	// Handlers run one by one on the gateway goroutine, so events are queued in the order they arrive
+	s.SyncEvents = true
+	h := &handlers.Handler{Store: store}
+	for _, handler := range h.Dispatched() {
+		s.AddHandler(handler)
+	}
+	dispatcher.Start()
 
 	// Start the bot's session
 	err = s.Open()
@@ -36,8 +53,29 @@ func Start() {
 		logger.Log.Panic(err)
 	}
 
+	// Start running periodic game tasks
+	scheduler.Start(store, s)
+
 	// Wait here until CTRL-C or other term signal is received.
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
 	<-sc
+
+	// Stop running periodic game tasks
+	scheduler.Stop()
+
+	// Finish handling queued events while the session is still open
+	dispatcher.Stop()
+
+	// Close the bot's session
+	err = s.Close()
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+
+	// Disconnect from the database
+	err = store.Close()
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
 }
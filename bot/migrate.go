@@ -0,0 +1,31 @@
+package bot
+
+import (
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+)
+
+// Migrate upgrades every stored Server to the current schema version without starting the bot.
+// With `dryRun` set, the pending migrations are only listed
+func Migrate(dryRun bool) {
+	// Connect to the database
+	store, err := db.NewMongoStore()
+	if err != nil {
+		logger.Log.Panic(err)
+	}
+	defer store.Close()
+
+	migrated, err := store.Migrate(dryRun)
+	for _, m := range migrated {
+		if dryRun {
+			logger.Log.Info("Server for Guild %s needs migration %d: %s.", m.Guild, m.Version, m.Description)
+		} else {
+			logger.Log.Info("Server for Guild %s migrated to version %d: %s.", m.Guild, m.Version, m.Description)
+		}
+	}
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	logger.Log.Info("%d migrations for schema version %d.", len(migrated), db.SchemaVersion)
+}
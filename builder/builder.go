@@ -7,49 +7,108 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
-// BuildServer initializes a new game on guild `g`
-func BuildServer(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) {
+// BuildServer initializes a new game on guild `g`.
+// Progress is stored as the build goes, so an interrupted build can be finished with ResumeBuild
+func BuildServer(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild) error {
 	logger.Log.Info("Building Server for Guild %s (id: %s)...", g.Name, g.ID)
 
-	// Build Discord Structure
-	buildRoles(server, s, g)
-	buildCategory(server, s, g)
-	buildChannels(server, s, g)
-	buildPermissions(server, s, g)
-	buildMessages(server, s, g)
-
 	// Update Server object
 	server.ID = g.ID
-	server.Playing = true
-	server.Users = []*structure.User{}
+	server.Playing = false
+	server.Building = true
+	server.Structure = structure.DefaultServer.Fingerprint()
 
 	// Upload to DB
-	err := db.CreateServer(server)
+	err := store.CreateServer(server)
 	if err != nil {
-		logger.Log.Error(err.Error())
-		return
+		return &BuildError{Guild: g.ID, Step: "server", Err: err}
+	}
+
+	return build(server, newTracker(server, store, s, g))
+}
+
+// ResumeBuild finishes building the game on guild `g` after an interrupted BuildServer
+func ResumeBuild(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild) error {
+	logger.Log.Info("Resuming build of Server for Guild %s (id: %s)...", g.Name, g.ID)
+
+	// Objects created by the interrupted build belong to this build
+	t := newTracker(server, store, s, g)
+	for _, r := range server.Roles {
+		if t.roles[r.ID] {
+			t.track("role", r.ID)
+		}
+	}
+	if t.channels[server.Category.ID] {
+		t.track("channel", server.Category.ID)
+	}
+	for _, c := range server.Channels {
+		if t.channels[c.ID] {
+			t.track("channel", c.ID)
+		}
+	}
+
+	return build(server, t)
+}
+
+func build(server *structure.Server, t *tracker) error {
+	s, g := t.session, t.guild
+
+	// Build Discord Structure
+	err := buildRoles(server, s, g, t)
+	if err == nil {
+		err = buildCategory(server, s, g, t)
+	}
+	if err == nil {
+		err = buildChannels(server, s, g, t)
+	}
+	if err == nil {
+		err = buildPermissions(server, s, g)
+	}
+	if err == nil {
+		err = buildMessages(server, s, g, t)
+	}
+	if err != nil {
+		logger.Log.Info("Rolling back Server for Guild %s (id: %s)...", g.Name, g.ID)
+		t.rollback()
+		if dbErr := t.store.DeleteServer(server); dbErr != nil {
+			logger.Log.Error(dbErr.Error())
+		}
+		return err
+	}
+
+	// Update Server object
+	err = db.Update(t.store, server, func(server *structure.Server) error {
+		server.Building = false
+		server.Playing = true
+		return t.store.UpdateServerBuilding(server)
+	})
+	if err != nil {
+		return &BuildError{Guild: g.ID, Step: "server", Err: err}
 	}
 
 	logger.Log.Info("Server for Guild %s (id: %s) successfully built.", g.Name, g.ID)
-	return
+	return nil
 }
 
 // DestroyServer removes game instance from guild `g`
-func DestroyServer(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) {
+func DestroyServer(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild) {
 	logger.Log.Info("Destroying Server %s (%s)...", g.Name, g.ID)
 
 	// Update Server Object
-	server.Playing = false
-	err := db.UpdateServerPlaying(server)
+	err := db.Update(store, server, func(server *structure.Server) error {
+		server.Playing = false
+		server.Paused = false
+		return store.UpdateServerPlaying(server)
+	})
 	if err != nil {
 		logger.Log.Error(err.Error())
 		return
 	}
 
 	// Destroy Discord Structure
-	destroyChannels(server, s, g)
-	destroyCategory(server, s, g)
-	destroyRoles(server, s, g)
+	if !server.Uninstalled {
+		destroyStructure(server, s, g)
+	}
 
 	// Leave Guild
 	err = s.GuildLeave(g.ID)
@@ -59,7 +118,7 @@ func DestroyServer(server *structure.Server, s *discordgo.Session, g *discordgo.
 	}
 
 	// Clear Server from DB
-	err = db.DeleteServer(server)
+	err = store.DeleteServer(server)
 	if err != nil {
 		logger.Log.Error(err.Error())
 		return
@@ -68,24 +127,124 @@ func DestroyServer(server *structure.Server, s *discordgo.Session, g *discordgo.
 	logger.Log.Info("Server %s (%s) successfully destroyed.", g.Name, g.ID)
 }
 
-func buildRoles(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) {
+// UninstallServer removes game instance from guild `g` but stays in it, so the game can be installed again later
+func UninstallServer(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild) {
+	logger.Log.Info("Uninstalling Server %s (%s)...", g.Name, g.ID)
+
+	// Update Server Object
+	err := db.Update(store, server, func(server *structure.Server) error {
+		server.Playing = false
+		server.Paused = false
+		return store.UpdateServerPlaying(server)
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	// Destroy Discord Structure
+	destroyStructure(server, s, g)
+
+	// Replace Server in DB with an uninstalled one in a single write, so the game is never missing from it
+	err = db.Update(store, server, func(server *structure.Server) error {
+		replacement := uninstalled(server)
+		return store.ReplaceServer(&replacement)
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	logger.Log.Info("Server %s (%s) successfully uninstalled.", g.Name, g.ID)
+}
+
+// storeStructure stores the Discord IDs of every Role, Channel and Message of `server` over the settings others changed meanwhile.
+// Nothing is stored once the game was stopped or uninstalled meanwhile, so its deleted parts are not brought back
+func storeStructure(server *structure.Server, store db.Store) error {
+	revision := server.Revision
+	target := *server
+	err := db.Update(store, &target, func(target *structure.Server) error {
+		if !target.Building && !target.Maintained() {
+			return nil
+		}
+		copyStructure(server, target)
+		return store.UpdateServerStructure(target)
+	})
+
+	// `server` is only up to date if nobody else changed it meanwhile, otherwise its next update loads it again
+	if err == nil && target.Revision == revision+1 {
+		server.Revision = target.Revision
+	}
+	return err
+}
+
+// copyStructure copies the Discord IDs of every Role, Channel and Message of `from` to `to`
+func copyStructure(from *structure.Server, to *structure.Server) {
+	to.EveryoneRole = from.EveryoneRole
+	to.Category.ID = from.Category.ID
+	for key, role := range from.Roles {
+		if r, ok := to.Roles[key]; ok {
+			r.ID = role.ID
+		}
+	}
+	for key, channel := range from.Channels {
+		if c, ok := to.Channels[key]; ok {
+			c.ID = channel.ID
+		}
+	}
+	for key, message := range from.Messages {
+		if m, ok := to.Messages[key]; ok {
+			m.ID = message.ID
+			m.ChannelID = message.ChannelID
+		}
+	}
+}
+
+// MarkUninstalled stores an uninstalled Server object for the Guild of `server`, which has none stored, keeping its settings
+func MarkUninstalled(server *structure.Server, store db.Store) error {
+	replacement := uninstalled(server)
+	return store.CreateServer(&replacement)
+}
+
+// uninstalled returns an uninstalled Server object for the Guild of `server`, at its revision and keeping its settings
+func uninstalled(server *structure.Server) structure.Server {
+	replacement := structure.DefaultServer.Copy()
+	replacement.ID = server.ID
+	replacement.Revision = server.Revision
+	replacement.Uninstalled = true
+	KeepSettings(server, &replacement)
+	return replacement
+}
+
+// KeepSettings copies the settings chosen by the administrators of `from` to `to`
+func KeepSettings(from *structure.Server, to *structure.Server) {
+	to.Prefix = from.Prefix
+	to.Overrides = append([]string{}, from.Overrides...)
+	to.AdminLog = from.AdminLog
+	to.Demotion = from.Demotion
+}
+
+func destroyStructure(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) {
+	destroyChannels(server, s, g)
+	destroyCategory(server, s, g)
+	destroyRoles(server, s, g)
+}
+
+func buildRoles(server *structure.Server, s *discordgo.Session, g *discordgo.Guild, t *tracker) error {
 	logger.Log.Info("Building Roles for server %s (%s)...", g.Name, g.ID)
 
-	for _, r := range server.Roles {
-		// Create Discord Role
-		role, err := s.GuildRoleCreate(g.ID)
+	for key, r := range server.Roles {
+		if t.roles[r.ID] {
+			continue
+		}
+		err := buildRole(server, s, g, r)
 		if err != nil {
-			logger.Log.Error(err.Error())
-			return
+			return &BuildError{Guild: g.ID, Step: "role", Key: key, Err: err}
 		}
-		_, err = s.GuildRoleEdit(g.ID, role.ID, r.DefaultName, 0, r.Hoist, server.RolePerm, r.Mentionable)
+		err = t.checkpoint("role", r.ID)
 		if err != nil {
-			logger.Log.Error(err.Error())
-			return
+			return &BuildError{Guild: g.ID, Step: "role", Key: key, Err: err}
 		}
-
-		// Update Server object
-		r.ID = role.ID
 	}
 
 	// Update Server Object
@@ -97,6 +256,23 @@ func buildRoles(server *structure.Server, s *discordgo.Session, g *discordgo.Gui
 	}
 
 	logger.Log.Info("Roles for server %s (%s) successfully built.", g.Name, g.ID)
+	return nil
+}
+
+func buildRole(server *structure.Server, s *discordgo.Session, g *discordgo.Guild, r *structure.Role) error {
+	// Create Discord Role
+	role, err := s.GuildRoleCreate(g.ID)
+	if err != nil {
+		return err
+	}
+	_, err = s.GuildRoleEdit(g.ID, role.ID, r.DefaultName, 0, r.Hoist, server.RolePerm, r.Mentionable)
+	if err != nil {
+		return err
+	}
+
+	// Update Server object
+	r.ID = role.ID
+	return nil
 }
 
 func destroyRoles(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) {
@@ -112,20 +288,34 @@ func destroyRoles(server *structure.Server, s *discordgo.Session, g *discordgo.G
 	logger.Log.Info("Roles from server %s (%s) successfully destroyed.", g.Name, g.ID)
 }
 
-func buildCategory(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) {
+func buildCategory(server *structure.Server, s *discordgo.Session, g *discordgo.Guild, t *tracker) error {
 	logger.Log.Info("Building Category for server %s (%s)", g.Name, g.ID)
 
+	if !t.channels[server.Category.ID] {
+		err := buildCategoryChannel(server, s, g)
+		if err != nil {
+			return &BuildError{Guild: g.ID, Step: "category", Err: err}
+		}
+		err = t.checkpoint("channel", server.Category.ID)
+		if err != nil {
+			return &BuildError{Guild: g.ID, Step: "category", Err: err}
+		}
+	}
+
+	logger.Log.Info("Category for server %s (%s) successfully built.", g.Name, g.ID)
+	return nil
+}
+
+func buildCategoryChannel(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) error {
 	// Create Discord Category
 	category, err := s.GuildChannelCreate(g.ID, server.Category.DefaultName, "4")
 	if err != nil {
-		logger.Log.Error(err.Error())
-		return
+		return err
 	}
 
 	// Update Server Object
 	server.Category.ID = category.ID
-
-	logger.Log.Info("Category for server %s (%s) successfully built.", g.Name, g.ID)
+	return nil
 }
 
 func destroyCategory(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) {
@@ -140,31 +330,45 @@ func destroyCategory(server *structure.Server, s *discordgo.Session, g *discordg
 	logger.Log.Info("Category from server %s (%s) successfully destroyed.", g.Name, g.ID)
 }
 
-func buildChannels(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) {
+func buildChannels(server *structure.Server, s *discordgo.Session, g *discordgo.Guild, t *tracker) error {
 	logger.Log.Info("Building Channels for server %s (%s)...", g.Name, g.ID)
 
-	for _, c := range server.Channels {
-		// Create Discord Channel
-		channel, err := s.GuildChannelCreate(g.ID, c.DefaultName, "0")
+	for key, c := range server.Channels {
+		if t.channels[c.ID] {
+			continue
+		}
+		err := buildChannel(server, s, g, c)
 		if err != nil {
-			logger.Log.Error(err.Error())
-			return
+			return &BuildError{Guild: g.ID, Step: "channel", Key: key, Err: err}
 		}
-		_, err = s.ChannelEditComplex(channel.ID, &discordgo.ChannelEdit{
-			ParentID: server.Category.ID,
-			Position: c.Position,
-			Topic:    c.Topic,
-		})
+		err = t.checkpoint("channel", c.ID)
 		if err != nil {
-			logger.Log.Error(err.Error())
-			return
+			return &BuildError{Guild: g.ID, Step: "channel", Key: key, Err: err}
 		}
-
-		// Update Server object
-		c.ID = channel.ID
 	}
 
 	logger.Log.Info("Channels for server %s (%s) successfully built.", g.Name, g.ID)
+	return nil
+}
+
+func buildChannel(server *structure.Server, s *discordgo.Session, g *discordgo.Guild, c *structure.Channel) error {
+	// Create Discord Channel
+	channel, err := s.GuildChannelCreate(g.ID, c.DefaultName, "0")
+	if err != nil {
+		return err
+	}
+	_, err = s.ChannelEditComplex(channel.ID, &discordgo.ChannelEdit{
+		ParentID: server.Category.ID,
+		Position: c.Position,
+		Topic:    c.Topic,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Update Server object
+	c.ID = channel.ID
+	return nil
 }
 
 func destroyChannels(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) {
@@ -180,89 +384,111 @@ func destroyChannels(server *structure.Server, s *discordgo.Session, g *discordg
 	logger.Log.Info("Channels from server %s (%s) successfully destroyed.", g.Name, g.ID)
 }
 
-func buildPermissions(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) {
+func buildPermissions(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) error {
 	logger.Log.Info("Building Permissions for server %s (%s)...", g.Name, g.ID)
 
 	bot, err := s.User("@me")
-	for _, channel := range server.Channels {
-		// Set Discord Permissions for Bot
-		err = s.ChannelPermissionSet(channel.ID, bot.ID, "member", server.BotPerm, 0)
+	if err != nil {
+		return &BuildError{Guild: g.ID, Step: "permissions", Err: err}
+	}
+	for key, channel := range server.Channels {
+		err = buildChannelPermissions(server, s, bot.ID, channel)
 		if err != nil {
-			logger.Log.Error(err.Error())
-			return
+			return &BuildError{Guild: g.ID, Step: "permissions", Key: key, Err: err}
 		}
+	}
 
-		if channel.Tier == 0 {
-			// Set Discord Permission for Hub Channel
-			err := s.ChannelPermissionSet(channel.ID, server.EveryoneRole, "role", server.ActionPerm, (server.BotPerm - server.ActionPerm))
-			if err != nil {
-				logger.Log.Error(err.Error())
-				return
+	logger.Log.Info("Permissions for server %s (%s) successfully built.", g.Name, g.ID)
+	return nil
+}
+
+func buildChannelPermissions(server *structure.Server, s *discordgo.Session, botID string, channel *structure.Channel) error {
+	// Set Discord Permissions for Bot
+	err := s.ChannelPermissionSet(channel.ID, botID, "member", server.BotPerm, 0)
+	if err != nil {
+		return err
+	}
+
+	if channel.Tier == 0 {
+		// Set Discord Permission for Hub Channel
+		return s.ChannelPermissionSet(channel.ID, server.EveryoneRole, "role", server.ActionPerm, (server.BotPerm - server.ActionPerm))
+	}
+
+	// Set Discord Permissions for Game/Social Channel
+	// @everyone Permissions
+	err = s.ChannelPermissionSet(channel.ID, server.EveryoneRole, "role", 0, server.BotPerm)
+	if err != nil {
+		return err
+	}
+
+	// Game Role Permissions
+	for _, role := range server.Roles {
+		if role.Tier >= channel.Tier {
+			if channel.Type == "social" {
+				// Social Channel
+				err = s.ChannelPermissionSet(channel.ID, role.ID, "role", server.SocialPerm, (server.BotPerm - server.SocialPerm))
+			} else if channel.Type == "action" {
+				// Game Channel, locked while the game is paused
+				err = s.ChannelPermissionSet(channel.ID, role.ID, "role", ActionPerm(server), (server.BotPerm - ActionPerm(server)))
+			} else if channel.Type == "announcement" {
+				// Announcement Channel, only written by the bot
+				err = s.ChannelPermissionSet(channel.ID, role.ID, "role", server.AnnouncePerm, (server.BotPerm - server.AnnouncePerm))
 			}
-		} else {
-			// Set Discord Permissions for Game/Social Channel
-			// @everyone Permissions
-			err := s.ChannelPermissionSet(channel.ID, server.EveryoneRole, "role", 0, server.BotPerm)
 			if err != nil {
-				logger.Log.Error(err.Error())
-				return
-			}
-
-			// Game Role Permissions
-			for _, role := range server.Roles {
-				if role.Tier >= channel.Tier {
-					if channel.Type == "social" {
-						// Social Channel
-						err := s.ChannelPermissionSet(channel.ID, role.ID, "role", server.SocialPerm, (server.BotPerm - server.SocialPerm))
-						if err != nil {
-							logger.Log.Error(err.Error())
-							return
-						}
-					} else if channel.Type == "action" {
-						// Game Channel
-						err := s.ChannelPermissionSet(channel.ID, role.ID, "role", server.ActionPerm, (server.BotPerm - server.ActionPerm))
-						if err != nil {
-							logger.Log.Error(err.Error())
-							return
-						}
-					}
-				}
+				return err
 			}
 		}
 	}
-
-	logger.Log.Info("Permissions for server %s (%s) successfully built.", g.Name, g.ID)
+	return nil
 }
 
-func buildMessages(server *structure.Server, s *discordgo.Session, g *discordgo.Guild) {
+func buildMessages(server *structure.Server, s *discordgo.Session, g *discordgo.Guild, t *tracker) error {
 	logger.Log.Info("Building Messages for Guild %s (id: %s)...", g.Name, g.ID)
 
 	// Send Messages
-	for _, message := range server.Messages {
-		// Create embed
-		embed := BuildEmbed(message)
-
-		// Send Message
-		m, err := s.ChannelMessageSendEmbed(server.Channels["rules"].ID, embed)
+	for key, message := range server.Messages {
+		if message.ID != "" && message.ChannelID == server.Channels["rules"].ID {
+			_, err := s.ChannelMessage(message.ChannelID, message.ID)
+			if err == nil {
+				continue
+			}
+		}
+		err := buildMessage(server, s, message)
 		if err != nil {
-			logger.Log.Error(err.Error())
-			return
+			return &BuildError{Guild: g.ID, Step: "message", Key: key, Err: err}
 		}
-
-		// Update Message object
-		message.ID = m.ID
-		message.ChannelID = m.ChannelID
-
-		// Add reactions
-		if message.Type == "info" {
-			err := s.MessageReactionAdd(message.ChannelID, message.ID, server.Actions["join"])
-			if err != nil {
-				logger.Log.Error(err.Error())
-			}
+		err = t.checkpoint("message", message.ID)
+		if err != nil {
+			return &BuildError{Guild: g.ID, Step: "message", Key: key, Err: err}
 		}
 	}
 
 	logger.Log.Info("Messages for server %s (%s) successfully built.", g.Name, g.ID)
+	return nil
+}
+
+func buildMessage(server *structure.Server, s *discordgo.Session, message *structure.Message) error {
+	// Create embed
+	embed := BuildEmbed(message)
+
+	// Send Message
+	m, err := s.ChannelMessageSendEmbed(server.Channels["rules"].ID, embed)
+	if err != nil {
+		return err
+	}
+
+	// Update Message object
+	message.ID = m.ID
+	message.ChannelID = m.ChannelID
+
+	// Add reactions
+	if message.Type == "info" {
+		err := s.MessageReactionAdd(message.ChannelID, message.ID, server.Actions["join"])
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+	}
+	return nil
 }
 
 // BuildEmbed creates a new Discord Embed
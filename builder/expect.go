@@ -0,0 +1,34 @@
+package builder
+
+import (
+	"sync"
+	"time"
+)
+
+// expectWindow is how long role enforcement ignores a game Role of a member after the game added or removed it
+const expectWindow = 10 * time.Second
+
+var expected = map[string]time.Time{}
+var expectedMu sync.Mutex
+
+// ExpectRoleChange marks the game Roles `roles` of member `user` of guild `guild` as being added or removed by the game
+func ExpectRoleChange(guild string, user string, roles ...string) {
+	expectedMu.Lock()
+	defer expectedMu.Unlock()
+	for _, role := range roles {
+		expected[guild+"/"+user+"/"+role] = time.Now().Add(expectWindow)
+	}
+}
+
+// RoleChangeExpected reports whether the game recently added or removed the game Role `role` of member `user` of guild `guild`
+func RoleChangeExpected(guild string, user string, role string) bool {
+	expectedMu.Lock()
+	defer expectedMu.Unlock()
+	key := guild + "/" + user + "/" + role
+	until, ok := expected[key]
+	if ok && time.Now().After(until) {
+		delete(expected, key)
+		return false
+	}
+	return ok
+}
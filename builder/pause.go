@@ -0,0 +1,99 @@
+package builder
+
+import (
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// lockedPerm are the permissions taken from game Roles in the action Channels of a paused game
+const lockedPerm = discordgo.PermissionSendMessages | discordgo.PermissionAddReactions
+
+// ActionPerm returns the permissions of game Roles in the action Channels of `server`, without the locked ones while it is paused
+func ActionPerm(server *structure.Server) int {
+	if server.Paused {
+		return server.ActionPerm &^ lockedPerm
+	}
+	return server.ActionPerm
+}
+
+// PauseServer stops the game of guild `g` and locks its action Channels, keeping the Discord structure maintained
+func PauseServer(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild) {
+	logger.Log.Info("Pausing Server for Guild %s (id: %s)...", g.Name, g.ID)
+
+	// Update Server object
+	err := db.Update(store, server, func(server *structure.Server) error {
+		server.Playing = false
+		server.Paused = true
+		return store.UpdateServerPlaying(server)
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	// Lock action Channels
+	for _, channel := range server.Channels {
+		if channel.Type != "action" || channel.Tier == 0 {
+			continue
+		}
+		for _, role := range server.Roles {
+			if role.Tier < channel.Tier {
+				continue
+			}
+			allow := ActionPerm(server)
+			err = s.ChannelPermissionSet(channel.ID, role.ID, "role", allow, server.BotPerm-allow)
+			if err != nil {
+				logger.Log.Error(err.Error())
+			}
+		}
+	}
+
+	Announce(server, s, "Game paused", "The game has been paused by an administrator. Action channels are locked until it resumes.")
+	logger.Log.Info("Server for Guild %s (id: %s) successfully paused.", g.Name, g.ID)
+}
+
+// ResumeServer restarts the paused game of guild `g`, restoring its permissions and any missing part of it
+func ResumeServer(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild) {
+	if !server.Paused || server.Building || server.Uninstalled {
+		logger.Log.Warning("Server for Guild %s (id: %s) is not paused, not resuming it.", g.Name, g.ID)
+		return
+	}
+	logger.Log.Info("Resuming Server for Guild %s (id: %s)...", g.Name, g.ID)
+
+	// Update Server object
+	err := db.Update(store, server, func(server *structure.Server) error {
+		server.Playing = true
+		server.Paused = false
+		return store.UpdateServerPlaying(server)
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	// Reapply permissions and rebuild missing parts
+	ReconcileServer(server, store, s, g)
+
+	Announce(server, s, "Game resumed", "The game has been resumed. Good luck, knights!")
+	logger.Log.Info("Server for Guild %s (id: %s) successfully resumed.", g.Name, g.ID)
+}
+
+// Announce sends an info embed to the announcements Channel of `server`
+func Announce(server *structure.Server, s *discordgo.Session, title string, description string) {
+	// Create announcement message
+	message := &structure.Message{
+		Title:       title,
+		Description: description,
+		Type:        "info",
+		Icon:        "https://cdn.discordapp.com/attachments/512302843437252611/512302951814004752/ac6918be09a389876ee5663d6b08b55a.png",
+		Footer:      "Game announcement.",
+	}
+
+	// Send announcement
+	_, err := s.ChannelMessageSendEmbed(server.Channels["announcements"].ID, BuildEmbed(message))
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+}
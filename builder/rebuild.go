@@ -0,0 +1,110 @@
+package builder
+
+import (
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// RebuildCategory recreates the deleted game Category of guild `g` and moves the game Channels back into it
+func RebuildCategory(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild) {
+	logger.Log.Info("Rebuilding Category for server %s (%s)...", g.Name, g.ID)
+
+	err := buildCategoryChannel(server, s, g)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	for _, c := range server.Channels {
+		_, err = s.ChannelEditComplex(c.ID, &discordgo.ChannelEdit{
+			ParentID: server.Category.ID,
+			Position: c.Position,
+		})
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+	}
+
+	// Update Server object
+	err = storeStructure(server, store)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	logger.Log.Info("Category for server %s (%s) successfully rebuilt.", g.Name, g.ID)
+}
+
+// RebuildChannel recreates the deleted game Channel `c` of guild `g` with its permissions and messages
+func RebuildChannel(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild, c *structure.Channel) {
+	logger.Log.Info("Rebuilding Channel %s for server %s (%s)...", c.DefaultName, g.Name, g.ID)
+
+	old := c.ID
+	err := buildChannel(server, s, g, c)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	bot, err := s.User("@me")
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	err = buildChannelPermissions(server, s, bot.ID, c)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+
+	// Resend Messages deleted with the Channel
+	for _, message := range server.Messages {
+		if message.ChannelID == old {
+			err = buildMessage(server, s, message)
+			if err != nil {
+				logger.Log.Error(err.Error())
+			}
+		}
+	}
+
+	// Update Server object
+	err = storeStructure(server, store)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	logger.Log.Info("Channel %s for server %s (%s) successfully rebuilt.", c.DefaultName, g.Name, g.ID)
+}
+
+// RebuildRole recreates the deleted game Role `r` of guild `g`, restores its permissions and gives it back to its Users
+func RebuildRole(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild, r *structure.Role) {
+	logger.Log.Info("Rebuilding Role %s for server %s (%s)...", r.DefaultName, g.Name, g.ID)
+
+	old := r.ID
+	err := buildRole(server, s, g, r)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	bot, err := s.User("@me")
+	if err != nil {
+		logger.Log.Error(err.Error())
+	} else {
+		for _, channel := range server.Channels {
+			err = buildChannelPermissions(server, s, bot.ID, channel)
+			if err != nil {
+				logger.Log.Error(err.Error())
+			}
+		}
+	}
+	ReassignRole(server, store, s, old, r.ID)
+
+	// Update Server object
+	err = storeStructure(server, store)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	logger.Log.Info("Role %s for server %s (%s) successfully rebuilt.", r.DefaultName, g.Name, g.ID)
+}
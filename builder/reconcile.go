@@ -0,0 +1,166 @@
+package builder
+
+import (
+	"net/http"
+
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ReconcileServer recreates every part of the game missing from guild `g` and stores the new Discord IDs
+func ReconcileServer(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild) {
+	logger.Log.Info("Reconciling Server for Guild %s (id: %s)...", g.Name, g.ID)
+
+	changed := false
+	roles := map[string]*discordgo.Role{}
+	for _, role := range g.Roles {
+		roles[role.ID] = role
+		if role.Name == "@everyone" && role.ID != server.EveryoneRole {
+			server.EveryoneRole = role.ID
+			changed = true
+		}
+	}
+	channels := map[string]*discordgo.Channel{}
+	for _, channel := range g.Channels {
+		channels[channel.ID] = channel
+	}
+
+	// Check Discord Roles
+	for _, r := range server.Roles {
+		if _, ok := roles[r.ID]; ok {
+			continue
+		}
+		old := r.ID
+		err := buildRole(server, s, g, r)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			continue
+		}
+		changed = true
+		ReassignRole(server, store, s, old, r.ID)
+	}
+
+	// Check Discord Category
+	if _, ok := channels[server.Category.ID]; !ok {
+		err := buildCategoryChannel(server, s, g)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		} else {
+			changed = true
+		}
+	}
+
+	// Check Discord Channels
+	for _, c := range server.Channels {
+		if channel, ok := channels[c.ID]; ok {
+			if channel.ParentID != server.Category.ID {
+				_, err := s.ChannelEditComplex(c.ID, &discordgo.ChannelEdit{
+					ParentID: server.Category.ID,
+					Position: c.Position,
+				})
+				if err != nil {
+					logger.Log.Error(err.Error())
+				}
+			}
+			continue
+		}
+		err := buildChannel(server, s, g, c)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			continue
+		}
+		changed = true
+	}
+
+	// Check Discord Permissions
+	bot, err := s.User("@me")
+	if err != nil {
+		logger.Log.Error(err.Error())
+	} else {
+		for _, channel := range server.Channels {
+			err = buildChannelPermissions(server, s, bot.ID, channel)
+			if err != nil {
+				logger.Log.Error(err.Error())
+			}
+		}
+	}
+
+	// Check Discord Messages
+	for _, message := range server.Messages {
+		if message.ChannelID == server.Channels["rules"].ID {
+			_, err := s.ChannelMessage(message.ChannelID, message.ID)
+			if !isNotFound(err) {
+				continue
+			}
+		}
+		err := buildMessage(server, s, message)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			continue
+		}
+		changed = true
+	}
+
+	// Update Server object
+	if changed {
+		err = storeStructure(server, store)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			return
+		}
+	}
+
+	logger.Log.Info("Server for Guild %s (id: %s) successfully reconciled.", g.Name, g.ID)
+}
+
+// RestoreServer resumes the archived game of guild `g`, unless it was paused, and recreates every part of it missing from the guild
+func RestoreServer(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild) {
+	logger.Log.Info("Restoring Server for Guild %s (id: %s)...", g.Name, g.ID)
+
+	// Update Server object
+	err := db.Update(store, server, func(server *structure.Server) error {
+		server.Archived = 0
+		server.Playing = !server.Paused
+		return store.UpdateServerArchived(server)
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	ReconcileServer(server, store, s, g)
+}
+
+// ReassignRole gives game Role `new` to every User who had the deleted game Role `old`
+func ReassignRole(server *structure.Server, store db.Store, s *discordgo.Session, old string, new string) {
+	users, err := store.GetServerUsers(server)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	for _, user := range users {
+		if user.Role != old {
+			continue
+		}
+		user.Role = new
+		ExpectRoleChange(server.ID, user.ID, new)
+		err := s.GuildMemberRoleAdd(server.ID, user.ID, new)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+	}
+
+	// Update User objects
+	err = store.ReplaceServerUserRole(server, old, new)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+}
+
+// isNotFound reports whether `err` is a Discord response for a missing object
+func isNotFound(err error) bool {
+	restErr, ok := err.(*discordgo.RESTError)
+	return ok && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
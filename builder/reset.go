@@ -0,0 +1,49 @@
+package builder
+
+import (
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ResetServer wipes the progress of the game on guild `g`, keeping its Discord structure and settings
+func ResetServer(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild) {
+	logger.Log.Info("Resetting Server %s (%s)...", g.Name, g.ID)
+
+	// Remove Game Roles from Users
+	users, err := store.GetServerUsers(server)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	for _, user := range users {
+		ExpectRoleChange(server.ID, user.ID, user.Role)
+		err := s.GuildMemberRoleRemove(server.ID, user.ID, user.Role)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+	}
+
+	// Remove active Cards
+	for _, card := range server.ActiveCards {
+		err := s.ChannelMessageDelete(card.ChannelID, card.MessageID)
+		if err != nil && !isNotFound(err) {
+			logger.Log.Error(err.Error())
+		}
+	}
+
+	// Update Server object
+	err = store.ResetServerProgress(server)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	server.ActiveCards = []*structure.ActiveCard{}
+	for _, resource := range server.Resources {
+		resource.Count = 0
+	}
+
+	Announce(server, s, "Game reset", "The game has been reset by the server's owner. Join again from the rules channel to start over.")
+	logger.Log.Info("Server %s (%s) successfully reset.", g.Name, g.ID)
+}
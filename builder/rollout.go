@@ -0,0 +1,455 @@
+package builder
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Change describes one difference between the game definition and the game of a guild.
+// Kind is "resource", "role", "category", "channel" or "message", Action is "add", "update" or "retire"
+type Change struct {
+	Kind   string
+	Key    string
+	Action string
+	Detail []string
+}
+
+// String describes the Change for reports
+func (c *Change) String() string {
+	text := map[string]string{"add": "Added", "update": "Updated", "retire": "Retired"}[c.Action] + " " + c.Kind + " `" + c.Key + "`"
+	if len(c.Detail) > 0 {
+		text += " (" + strings.Join(c.Detail, ", ") + ")"
+	}
+	return text
+}
+
+// Diff compares the game definition `server` with the Server object `stored` in the DB and its guild `g`.
+// `messages` contains the Discord Messages of the game found in the guild by key
+func Diff(server *structure.Server, stored *structure.Server, g *discordgo.Guild, messages map[string]*discordgo.Message) []*Change {
+	changes := []*Change{}
+	roles := map[string]*discordgo.Role{}
+	for _, role := range g.Roles {
+		roles[role.ID] = role
+	}
+	channels := map[string]*discordgo.Channel{}
+	for _, channel := range g.Channels {
+		channels[channel.ID] = channel
+	}
+
+	// Resources
+	for _, key := range sortedKeys(server.Resources) {
+		if _, ok := stored.Resources[key]; !ok {
+			changes = append(changes, &Change{Kind: "resource", Key: key, Action: "add"})
+		}
+	}
+	for _, key := range sortedKeys(stored.Resources) {
+		if _, ok := server.Resources[key]; !ok {
+			changes = append(changes, &Change{Kind: "resource", Key: key, Action: "retire"})
+		}
+	}
+
+	// Roles
+	for _, key := range sortedKeys(server.Roles) {
+		r := server.Roles[key]
+		if r.ID == "" {
+			changes = append(changes, &Change{Kind: "role", Key: key, Action: "add"})
+			continue
+		}
+		role, ok := roles[r.ID]
+		if !ok {
+			continue
+		}
+		detail := []string{}
+		if role.Name != r.DefaultName {
+			detail = append(detail, "name")
+		}
+		if role.Hoist != r.Hoist {
+			detail = append(detail, "hoist")
+		}
+		if role.Mentionable != r.Mentionable {
+			detail = append(detail, "mentionable")
+		}
+		if role.Permissions != server.RolePerm {
+			detail = append(detail, "permissions")
+		}
+		if len(detail) > 0 {
+			changes = append(changes, &Change{Kind: "role", Key: key, Action: "update", Detail: detail})
+		}
+	}
+	for _, key := range sortedKeys(stored.Roles) {
+		if _, ok := server.Roles[key]; !ok {
+			changes = append(changes, &Change{Kind: "role", Key: key, Action: "retire"})
+		}
+	}
+
+	// Category
+	if category, ok := channels[server.Category.ID]; ok && category.Name != server.Category.DefaultName {
+		changes = append(changes, &Change{Kind: "category", Key: "category", Action: "update", Detail: []string{"name"}})
+	}
+
+	// Channels
+	misplaced := misplacedChannels(server, channels)
+	for _, key := range sortedKeys(server.Channels) {
+		c := server.Channels[key]
+		if c.ID == "" {
+			changes = append(changes, &Change{Kind: "channel", Key: key, Action: "add"})
+			continue
+		}
+		channel, ok := channels[c.ID]
+		if !ok {
+			continue
+		}
+		detail := []string{}
+		if channel.Name != c.DefaultName {
+			detail = append(detail, "name")
+		}
+		if channel.Topic != c.Topic {
+			detail = append(detail, "topic")
+		}
+		if misplaced[key] {
+			detail = append(detail, "position")
+		}
+		if len(detail) > 0 {
+			changes = append(changes, &Change{Kind: "channel", Key: key, Action: "update", Detail: detail})
+		}
+	}
+	for _, key := range sortedKeys(stored.Channels) {
+		if _, ok := server.Channels[key]; !ok {
+			changes = append(changes, &Change{Kind: "channel", Key: key, Action: "retire"})
+		}
+	}
+
+	// Messages
+	for _, key := range sortedKeys(server.Messages) {
+		m := server.Messages[key]
+		if m.ID == "" {
+			changes = append(changes, &Change{Kind: "message", Key: key, Action: "add"})
+			continue
+		}
+		message, ok := messages[key]
+		if !ok || len(message.Embeds) == 0 {
+			continue
+		}
+		if !sameEmbed(message.Embeds[0], BuildEmbed(m)) {
+			changes = append(changes, &Change{Kind: "message", Key: key, Action: "update", Detail: []string{"content"}})
+		}
+	}
+	for _, key := range sortedKeys(stored.Messages) {
+		if _, ok := server.Messages[key]; !ok {
+			changes = append(changes, &Change{Kind: "message", Key: key, Action: "retire"})
+		}
+	}
+
+	return changes
+}
+
+// RolloutServer applies the changes of the game definition to the running game of guild `g`, returning what changed
+func RolloutServer(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild) []*Change {
+	logger.Log.Info("Rolling out structure to Server for Guild %s (id: %s)...", g.Name, g.ID)
+
+	// Every attempt is stored, so a failing Server is tried again after the other Servers had their turn.
+	// Only a complete rollout moves the Server to the new structure
+	complete := false
+	defer func() {
+		tried := time.Now().Unix()
+		err := db.Update(store, server, func(server *structure.Server) error {
+			server.RolloutTried = tried
+			if complete {
+				server.Structure = structure.DefaultServer.Fingerprint()
+			}
+			return store.UpdateServerFingerprint(server)
+		})
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+	}()
+
+	// Find differences
+	stored, err := store.GetServerStructure(server.ID)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return nil
+	}
+	messages := map[string]*discordgo.Message{}
+	for key, m := range server.Messages {
+		if m.ID == "" {
+			continue
+		}
+		message, err := s.ChannelMessage(m.ChannelID, m.ID)
+		if err == nil {
+			messages[key] = message
+		}
+	}
+	changes := Diff(server, stored, g, messages)
+
+	// Apply differences, retiring before adding so replaced parts do not clash
+	applied := []*Change{}
+	retired := map[string][]string{}
+	for _, action := range []string{"retire", "add", "update"} {
+		for _, change := range changes {
+			if change.Action != action {
+				continue
+			}
+			err = apply(server, stored, store, s, g, change)
+			if err != nil {
+				logger.Log.Error(err.Error())
+				continue
+			}
+			if action == "retire" {
+				retired[change.Kind+"s"] = append(retired[change.Kind+"s"], change.Key)
+			}
+			applied = append(applied, change)
+		}
+	}
+
+	done := len(applied) == len(changes)
+
+	// Set permissions for new Roles and Channels
+	bot, err := s.User("@me")
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return applied
+	}
+	for _, channel := range server.Channels {
+		err = buildChannelPermissions(server, s, bot.ID, channel)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			done = false
+		}
+	}
+
+	// Update Server object
+	err = storeStructure(server, store)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return applied
+	}
+	err = db.Update(store, server, func(server *structure.Server) error {
+		return store.RemoveServerStructure(server, retired)
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return applied
+	}
+	complete = done
+
+	report(server, s, g, applied)
+	if !complete {
+		logger.Log.Warning("Structure partially rolled out to Server for Guild %s (id: %s) with %d of %d changes, trying again later.", g.Name, g.ID, len(applied), len(changes))
+		return applied
+	}
+	logger.Log.Info("Structure rolled out to Server for Guild %s (id: %s) with %d of %d changes.", g.Name, g.ID, len(applied), len(changes))
+	return applied
+}
+
+// apply makes Change `c` to the game of guild `g`
+func apply(server *structure.Server, stored *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild, c *Change) error {
+	// Retired parts which were never built only live in the DB
+	if c.Action == "retire" {
+		switch {
+		case c.Kind == "role" && (stored.Roles[c.Key] == nil || stored.Roles[c.Key].ID == ""),
+			c.Kind == "channel" && (stored.Channels[c.Key] == nil || stored.Channels[c.Key].ID == ""),
+			c.Kind == "message" && (stored.Messages[c.Key] == nil || stored.Messages[c.Key].ID == ""):
+			return nil
+		}
+	}
+
+	switch c.Kind + "/" + c.Action {
+	case "role/add":
+		return buildRole(server, s, g, server.Roles[c.Key])
+	case "role/update":
+		r := server.Roles[c.Key]
+		_, err := s.GuildRoleEdit(g.ID, r.ID, r.DefaultName, 0, r.Hoist, server.RolePerm, r.Mentionable)
+		return err
+	case "role/retire":
+		return retireRole(server, store, s, g, stored.Roles[c.Key].ID)
+	case "category/update":
+		_, err := s.ChannelEdit(server.Category.ID, server.Category.DefaultName)
+		return err
+	case "channel/add":
+		return buildChannel(server, s, g, server.Channels[c.Key])
+	case "channel/update":
+		channel := server.Channels[c.Key]
+		_, err := s.ChannelEditComplex(channel.ID, &discordgo.ChannelEdit{
+			Name:     channel.DefaultName,
+			Topic:    channel.Topic,
+			Position: channel.Position,
+			ParentID: server.Category.ID,
+		})
+		return err
+	case "channel/retire":
+		_, err := s.ChannelDelete(stored.Channels[c.Key].ID)
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	case "message/add":
+		return buildMessage(server, s, server.Messages[c.Key])
+	case "message/update":
+		message := server.Messages[c.Key]
+		_, err := s.ChannelMessageEditEmbed(message.ChannelID, message.ID, BuildEmbed(message))
+		return err
+	case "message/retire":
+		message := stored.Messages[c.Key]
+		err := s.ChannelMessageDelete(message.ChannelID, message.ID)
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	// Resources only live in the DB
+	return nil
+}
+
+// retireRole moves every User with the retired game Role `id` to the highest remaining game Role they qualify for, then deletes it
+func retireRole(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild, id string) error {
+	users, err := store.GetServerUsers(server)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if user.Role != id {
+			continue
+		}
+		key := ""
+		for k, role := range server.Roles {
+			if role.Threshold <= user.Contribution && (key == "" || role.Tier > server.Roles[key].Tier) {
+				key = k
+			}
+		}
+		if key == "" {
+			continue
+		}
+		ExpectRoleChange(server.ID, user.ID, server.Roles[key].ID)
+		err = s.GuildMemberRoleAdd(server.ID, user.ID, server.Roles[key].ID)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			continue
+		}
+		user.Role = server.Roles[key].ID
+		err = store.UpdateServerUserRole(server, user)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+	}
+
+	err = s.GuildRoleDelete(g.ID, id)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// report sends the changes of a rollout to the admin log Channel of `server`, or to its announcements Channel if it has none
+func report(server *structure.Server, s *discordgo.Session, g *discordgo.Guild, changes []*Change) {
+	if len(changes) == 0 {
+		return
+	}
+	lines := []string{}
+	for _, change := range changes {
+		lines = append(lines, "• "+change.String())
+		logger.Log.Info("Guild %s (id: %s): %s.", g.Name, g.ID, change.String())
+	}
+	if server.AdminLog == "" {
+		Announce(server, s, "Game updated", strings.Join(lines, "\n"))
+		return
+	}
+
+	// Create report message
+	message := &structure.Message{
+		Title:       "Game updated",
+		Description: strings.Join(lines, "\n"),
+		Type:        "system",
+		Icon:        "https://cdn.discordapp.com/attachments/512302843437252611/512302951814004752/ac6918be09a389876ee5663d6b08b55a.png",
+		Footer:      "Structure rollout report.",
+	}
+	_, err := s.ChannelMessageSendEmbed(server.AdminLog, BuildEmbed(message))
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+}
+
+// misplacedChannels returns the keys of the built Channels of `server` whose place among the game Channels of `channels` differs from the game definition.
+// Discord moves positions around as other Channels are added, so only the order of the game Channels is compared
+func misplacedChannels(server *structure.Server, channels map[string]*discordgo.Channel) map[string]bool {
+	built := []string{}
+	for _, key := range sortedKeys(server.Channels) {
+		if _, ok := channels[server.Channels[key].ID]; ok {
+			built = append(built, key)
+		}
+	}
+
+	// Order by game definition
+	wanted := append([]string{}, built...)
+	sort.SliceStable(wanted, func(i, j int) bool {
+		return server.Channels[wanted[i]].Position < server.Channels[wanted[j]].Position
+	})
+
+	// Order as Discord shows them, by position and then by age
+	actual := append([]string{}, built...)
+	sort.SliceStable(actual, func(i, j int) bool {
+		a, b := channels[server.Channels[actual[i]].ID], channels[server.Channels[actual[j]].ID]
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		if len(a.ID) != len(b.ID) {
+			return len(a.ID) < len(b.ID)
+		}
+		return a.ID < b.ID
+	})
+
+	misplaced := map[string]bool{}
+	for i := range built {
+		if wanted[i] != actual[i] {
+			misplaced[wanted[i]] = true
+		}
+	}
+	return misplaced
+}
+
+// sameEmbed reports whether the game content of embeds `a` and `b` is the same
+func sameEmbed(a *discordgo.MessageEmbed, b *discordgo.MessageEmbed) bool {
+	if a.Title != b.Title || a.Description != b.Description || len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	if (a.Footer == nil) != (b.Footer == nil) || (a.Footer != nil && a.Footer.Text != b.Footer.Text) {
+		return false
+	}
+	for i := range a.Fields {
+		if a.Fields[i].Name != b.Fields[i].Name || a.Fields[i].Value != b.Fields[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedKeys returns the keys of map `m` in order, so reports are stable
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch typed := m.(type) {
+	case map[string]*structure.Resource:
+		for key := range typed {
+			keys = append(keys, key)
+		}
+	case map[string]*structure.Role:
+		for key := range typed {
+			keys = append(keys, key)
+		}
+	case map[string]*structure.Channel:
+		for key := range typed {
+			keys = append(keys, key)
+		}
+	case map[string]*structure.Message:
+		for key := range typed {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,90 @@
+package builder
+
+import (
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// BuildError describes the step of a Server build that failed
+type BuildError struct {
+	Guild string
+	Step  string
+	Key   string
+	Err   error
+}
+
+func (e *BuildError) Error() string {
+	if e.Key != "" {
+		return "building " + e.Step + " " + e.Key + " for guild " + e.Guild + ": " + e.Err.Error()
+	}
+	return "building " + e.Step + " for guild " + e.Guild + ": " + e.Err.Error()
+}
+
+// created is a Discord object made by a Server build
+type created struct {
+	kind string
+	id   string
+}
+
+// tracker records the Discord objects made by a Server build so they can be rolled back
+type tracker struct {
+	server   *structure.Server
+	store    db.Store
+	session  *discordgo.Session
+	guild    *discordgo.Guild
+	roles    map[string]bool
+	channels map[string]bool
+	created  []created
+}
+
+func newTracker(server *structure.Server, store db.Store, s *discordgo.Session, g *discordgo.Guild) *tracker {
+	t := &tracker{
+		server:   server,
+		store:    store,
+		session:  s,
+		guild:    g,
+		roles:    map[string]bool{},
+		channels: map[string]bool{},
+	}
+
+	// Existing Discord objects are never rebuilt
+	for _, role := range g.Roles {
+		t.roles[role.ID] = true
+	}
+	for _, channel := range g.Channels {
+		t.channels[channel.ID] = true
+	}
+	return t
+}
+
+// track adds a Discord object to the ones rolled back on failure
+func (t *tracker) track(kind string, id string) {
+	t.created = append(t.created, created{kind: kind, id: id})
+}
+
+// checkpoint tracks a new Discord object and stores the build progress
+func (t *tracker) checkpoint(kind string, id string) error {
+	if kind != "message" {
+		t.track(kind, id)
+	}
+	return storeStructure(t.server, t.store)
+}
+
+// rollback deletes every tracked Discord object, newest first
+func (t *tracker) rollback() {
+	for i := len(t.created) - 1; i >= 0; i-- {
+		var err error
+		switch t.created[i].kind {
+		case "role":
+			err = t.session.GuildRoleDelete(t.guild.ID, t.created[i].id)
+		case "channel":
+			_, err = t.session.ChannelDelete(t.created[i].id)
+		}
+		if err != nil && !isNotFound(err) {
+			logger.Log.Error(err.Error())
+		}
+	}
+	t.created = nil
+}
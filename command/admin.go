@@ -0,0 +1,177 @@
+package command
+
+import (
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Admin command, groups the commands managing the game of a server
+type Admin struct{}
+
+// Execute method for Admin command
+func (a *Admin) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	sendUsage(server, s, m.ChannelID, "Choose an admin command.", []string{a.Trigger()}, a)
+}
+
+// Trigger for Admin command
+func (*Admin) Trigger() string {
+	return "admin"
+}
+
+// Aliases for Admin command
+func (*Admin) Aliases() []string {
+	return nil
+}
+
+// Description for Admin command
+func (*Admin) Description() string {
+	return "Manage the game running on this server.\nOnly the server's owner and administrators can execute these commands.\n"
+}
+
+// Params for Admin command
+func (*Admin) Params() []Param {
+	return nil
+}
+
+// Requirements for Admin command
+func (*Admin) Requirements() Requirements {
+	return Requirements{Admin: true, Uninstalled: true}
+}
+
+// Cooldown for Admin command
+func (*Admin) Cooldown() Cooldown {
+	return Cooldown{User: 5}
+}
+
+// Subcommands for Admin command
+func (*Admin) Subcommands() []Response {
+	return []Response{
+		&OverrideRole{},
+		&AdminLog{},
+		&SetPrefix{},
+	}
+}
+
+// OverrideRole command
+type OverrideRole struct{}
+
+// Execute method for OverrideRole command
+func (*OverrideRole) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	// Toggle given Roles and update Server object
+	err := db.Update(store, server, func(server *structure.Server) error {
+		for _, id := range args["roles"] {
+			overrides := []string{}
+			for _, o := range server.Overrides {
+				if o != id {
+					overrides = append(overrides, o)
+				}
+			}
+			if len(overrides) == len(server.Overrides) {
+				overrides = append(overrides, id)
+			}
+			server.Overrides = overrides
+		}
+		return store.UpdateServerAdmin(server)
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	description := "No roles may change game roles by hand."
+	if len(server.Overrides) > 0 {
+		description = "Roles that may change game roles by hand:"
+		for _, o := range server.Overrides {
+			description += " <@&" + o + ">"
+		}
+	}
+	sendFeedback(s, m.ChannelID, description)
+}
+
+// Trigger for OverrideRole command
+func (*OverrideRole) Trigger() string {
+	return "override"
+}
+
+// Aliases for OverrideRole command
+func (*OverrideRole) Aliases() []string {
+	return []string{"overrides"}
+}
+
+// Description for OverrideRole command
+func (*OverrideRole) Description() string {
+	return "Allow or stop members of the given roles to change the game roles of players by hand.\nOnly the server's owner and administrators can execute this command.\n"
+}
+
+// Params for OverrideRole command
+func (*OverrideRole) Params() []Param {
+	return []Param{{Name: "roles", Type: "role", Variadic: true}}
+}
+
+// Requirements for OverrideRole command
+func (*OverrideRole) Requirements() Requirements {
+	return Requirements{Admin: true}
+}
+
+// Cooldown for OverrideRole command
+func (*OverrideRole) Cooldown() Cooldown {
+	return Cooldown{Guild: 5}
+}
+
+// AdminLog command
+type AdminLog struct{}
+
+// Execute method for AdminLog command
+func (*AdminLog) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	// Update Server object
+	err := db.Update(store, server, func(server *structure.Server) error {
+		if server.AdminLog == m.ChannelID {
+			server.AdminLog = ""
+		} else {
+			server.AdminLog = m.ChannelID
+		}
+		return store.UpdateServerAdmin(server)
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	if server.AdminLog != "" {
+		sendFeedback(s, m.ChannelID, "Game role corrections will be logged in this channel.")
+	} else {
+		sendFeedback(s, m.ChannelID, "Game role corrections will no longer be logged.")
+	}
+}
+
+// Trigger for AdminLog command
+func (*AdminLog) Trigger() string {
+	return "log"
+}
+
+// Aliases for AdminLog command
+func (*AdminLog) Aliases() []string {
+	return nil
+}
+
+// Description for AdminLog command
+func (*AdminLog) Description() string {
+	return "Log game role corrections in this channel, or stop logging them.\nOnly the server's owner and administrators can execute this command.\n"
+}
+
+// Params for AdminLog command
+func (*AdminLog) Params() []Param {
+	return nil
+}
+
+// Requirements for AdminLog command
+func (*AdminLog) Requirements() Requirements {
+	return Requirements{Admin: true}
+}
+
+// Cooldown for AdminLog command
+func (*AdminLog) Cooldown() Cooldown {
+	return Cooldown{Guild: 5}
+}
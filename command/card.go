@@ -0,0 +1,98 @@
+package command
+
+import (
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/game"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ClaimCard command structure, one per Card choice reaction
+type ClaimCard struct {
+	Action string
+}
+
+// Execute method for ClaimCard command
+func (c *ClaimCard) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageReactionAdd) {
+	if !server.Playing {
+		return
+	}
+
+	// Check for active Card
+	active := activeCard(server, m.MessageID)
+	if active == nil {
+		return
+	}
+	card, ok := server.Cards[active.Card]
+	if !ok {
+		return
+	}
+
+	// Check for Card choice
+	var choice *structure.Choice
+	for _, ch := range card.Choices {
+		if ch.Action == c.Action {
+			choice = ch
+			break
+		}
+	}
+	if choice == nil {
+		return
+	}
+
+	// Check if user is playing
+	user, err := store.GetServerUser(server, m.UserID)
+	if err != nil {
+		if err != db.NotFound {
+			logger.Log.Error(err.Error())
+		}
+		return
+	}
+
+	// Update Server object
+	claimed, err := store.ClaimServerCard(server, active, user, choice.Costs, choice.Rewards, choice.Contribution)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	if !claimed {
+		err = s.MessageReactionRemove(m.ChannelID, m.MessageID, m.Emoji.APIName(), m.UserID)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+		sendFeedback(s, m.ChannelID, "\""+card.Title+"\" can not be claimed. It may have expired, run out of claims, or your server can not afford it.")
+		return
+	}
+
+	sendFeedback(s, m.ChannelID, "\""+card.Title+"\" claimed: "+choice.Label+".")
+
+	// Check for promotion
+	user.Contribution += choice.Contribution
+	game.UpdateTier(server, store, s, user)
+}
+
+// Trigger for ClaimCard command
+func (c *ClaimCard) Trigger() string {
+	return structure.DefaultServer.Actions[c.Action]
+}
+
+// Cooldown for ClaimCard command
+func (c *ClaimCard) Cooldown() Cooldown {
+	return Cooldown{User: 2}
+}
+
+// Targets for ClaimCard command, only reactions on active Cards claim them
+func (c *ClaimCard) Targets(server *structure.Server, m *discordgo.MessageReactionAdd) bool {
+	return activeCard(server, m.MessageID) != nil
+}
+
+// activeCard returns the active Card of `server` posted as Message `id`, nil if there is none
+func activeCard(server *structure.Server, id string) *structure.ActiveCard {
+	for _, card := range server.ActiveCards {
+		if card.MessageID == id {
+			return card
+		}
+	}
+	return nil
+}
@@ -1,58 +1,72 @@
 package command
 
 import (
+	"strings"
+	"time"
+
 	"github.com/Noxdew/Knights-Of-Discord/builder"
 	"github.com/Noxdew/Knights-Of-Discord/db"
 	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/scheduler"
 	"github.com/Noxdew/Knights-Of-Discord/structure"
 	"github.com/bwmarrin/discordgo"
 )
 
-// Action interface for parsing reaction commands
+// Action interface for parsing reaction commands.
+// Targets reports whether a reaction is on a Message the command handles, before its cooldown is claimed
 type Action interface {
 	Trigger() string
-	Execute(*structure.Server, *discordgo.Session, *discordgo.MessageReactionAdd)
+	Cooldown() Cooldown
+	Targets(*structure.Server, *discordgo.MessageReactionAdd) bool
+	Execute(*structure.Server, db.Store, *discordgo.Session, *discordgo.MessageReactionAdd)
 }
 
 // Response interface for parsing message commands
 type Response interface {
 	Trigger() string
+	Aliases() []string
 	Description() string
-	Execute(*structure.Server, *discordgo.Session, *discordgo.MessageCreate)
+	Params() []Param
+	Requirements() Requirements
+	Cooldown() Cooldown
+	Execute(*structure.Server, db.Store, *discordgo.Session, *discordgo.MessageCreate, Args)
 }
 
 // AddUser command structure
 type AddUser struct{}
 
 // Execute method for AddUser command
-func (*AddUser) Execute(server *structure.Server, s *discordgo.Session, m *discordgo.MessageReactionAdd) {
-	// Check for right message
-	if server.Messages["rules"].ID != m.MessageID {
+func (*AddUser) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageReactionAdd) {
+	// Check for running game
+	if !server.Playing {
 		return
 	}
 
-	// Check is User exists
-	for _, user := range server.Users {
-		if user.ID == m.UserID {
-			return
-		}
+	// Add User, the store only keeps one of concurrent reactions
+	user := &structure.User{
+		ID:           m.UserID,
+		Role:         server.Roles["r1"].ID,
+		Contribution: 0,
+		Gathered:     map[string]int64{},
+		LastActive:   time.Now().Unix(),
 	}
-
-	// Assign Game Role to User
-	err := s.GuildMemberRoleAdd(server.ID, m.UserID, server.Roles["r1"].ID)
+	err := store.AddServerUser(server, user)
 	if err != nil {
-		logger.Log.Error(err.Error())
+		if err != db.Duplicate {
+			logger.Log.Error(err.Error())
+		}
 		return
 	}
 
-	// Update Server object
-	err = db.AddServerUser(server, &structure.User{
-		ID:           m.UserID,
-		Role:         server.Roles["r1"].ID,
-		Contribution: 0,
-	})
+	// Assign Game Role to User, removing them again if it fails
+	builder.ExpectRoleChange(server.ID, m.UserID, server.Roles["r1"].ID)
+	err = s.GuildMemberRoleAdd(server.ID, m.UserID, server.Roles["r1"].ID)
 	if err != nil {
 		logger.Log.Error(err.Error())
+		err = store.RemoveServerUser(server, user)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
 		return
 	}
 
@@ -67,28 +81,46 @@ func (*AddUser) Execute(server *structure.Server, s *discordgo.Session, m *disco
 
 // Trigger for AddUser command
 func (*AddUser) Trigger() string {
-	return "514099648949125153"
+	return structure.DefaultServer.Actions["join"]
+}
+
+// Cooldown for AddUser command
+func (*AddUser) Cooldown() Cooldown {
+	return Cooldown{User: 300, Persist: true}
+}
+
+// Targets for AddUser command, only reactions on the rules Message join the game
+func (*AddUser) Targets(server *structure.Server, m *discordgo.MessageReactionAdd) bool {
+	return server.Messages["rules"].ID == m.MessageID
 }
 
 // ReactionCommands array
 var ReactionCommands = []Action{
 	&AddUser{},
+	&ClaimCard{Action: "choiceA"},
+	&ClaimCard{Action: "choiceB"},
+	&ClaimCard{Action: "choiceC"},
+	&Respond{Action: "confirm"},
+	&Respond{Action: "cancel"},
 }
 
 // CloseGame command
 type CloseGame struct{}
 
 // Execute method for CloseGame command
-func (*CloseGame) Execute(server *structure.Server, s *discordgo.Session, m *discordgo.MessageCreate) {
-	// Check if Guild admin issued the command
+func (*CloseGame) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	Confirm(server, s, m, "Close the game?", "All progress and resources of this server will be lost.", func(server *structure.Server, store db.Store, s *discordgo.Session) {
+		closeGame(server, store, s, m.ChannelID)
+	})
+}
+
+// closeGame removes the game from the server after the owner confirmed it
+func closeGame(server *structure.Server, store db.Store, s *discordgo.Session, channel string) {
 	g, err := s.Guild(server.ID)
 	if err != nil {
 		logger.Log.Error(err.Error())
 		return
 	}
-	if g.OwnerID != m.Author.ID {
-		return
-	}
 
 	// Create response message
 	message := &structure.Message{
@@ -102,11 +134,18 @@ func (*CloseGame) Execute(server *structure.Server, s *discordgo.Session, m *dis
 	embed := builder.BuildEmbed(message)
 
 	// Send response
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	_, err = s.ChannelMessageSendEmbed(channel, embed)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+	builder.DestroyServer(server, store, s, g)
+	ForgetPrefix(server.ID)
+
+	// Stop periodic game tasks
+	err = scheduler.Unschedule(server, store)
 	if err != nil {
 		logger.Log.Error(err.Error())
 	}
-	builder.DestroyServer(server, s, g)
 }
 
 // Trigger for CloseGame command
@@ -114,16 +153,36 @@ func (*CloseGame) Trigger() string {
 	return "closeGame"
 }
 
+// Aliases for CloseGame command
+func (*CloseGame) Aliases() []string {
+	return nil
+}
+
 // Description for CloseGame command
 func (*CloseGame) Description() string {
-	return "Close the game running on this server.\nOnly the server's owner can execute this command.\n**WARNING!** Closing the game will result in losing all progress and resources of your server!\n"
+	return "Close the game running on this server and remove the bot from it.\nUse `uninstall` instead to keep the bot, or `resetGame` to only start over.\nOnly the server's owner can execute this command.\n**WARNING!** Closing the game will result in losing all progress and resources of your server!\nYou will be asked to confirm.\n"
+}
+
+// Params for CloseGame command
+func (*CloseGame) Params() []Param {
+	return nil
+}
+
+// Requirements for CloseGame command
+func (*CloseGame) Requirements() Requirements {
+	return Requirements{Owner: true, Uninstalled: true}
+}
+
+// Cooldown for CloseGame command
+func (*CloseGame) Cooldown() Cooldown {
+	return Cooldown{Guild: 10}
 }
 
 // Help command
 type Help struct{}
 
 // Execute method for Help command
-func (*Help) Execute(server *structure.Server, s *discordgo.Session, m *discordgo.MessageCreate) {
+func (*Help) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
 	// Create response message
 	message := &structure.Message{
 		Title:  "Command List",
@@ -133,12 +192,24 @@ func (*Help) Execute(server *structure.Server, s *discordgo.Session, m *discordg
 		Fields: []*structure.Field{},
 	}
 
-	// Add fields
-	for _, cmd := range MessageCommands {
-		message.Fields = append(message.Fields, &structure.Field{
-			Title: "`!kod-" + cmd.Trigger() + "`",
-			Value: cmd.Description(),
-		})
+	if args.Get("command") != "" {
+		// Find requested command
+		path, cmd, rest := resolve(MessageCommands, strings.Fields(args.Get("command")))
+		if cmd == nil || len(rest) > 0 {
+			sendFeedback(s, m.ChannelID, "Unknown command `"+args.Get("command")+"`. Try using `"+Prefix(server)+"help` for a list of all game commands.")
+			return
+		}
+		message.Title = Usage(server, path, cmd)
+		message.Description = cmd.Description()
+		message.Fields = usageFields(server, path, cmd)
+	} else {
+		// Add fields
+		for _, cmd := range MessageCommands {
+			message.Fields = append(message.Fields, &structure.Field{
+				Title: "`" + Usage(server, []string{cmd.Trigger()}, cmd) + "`",
+				Value: cmd.Description(),
+			})
+		}
 	}
 
 	// Build Embed
@@ -156,43 +227,79 @@ func (*Help) Trigger() string {
 	return "help"
 }
 
+// Aliases for Help command
+func (*Help) Aliases() []string {
+	return []string{"commands"}
+}
+
 // Description for Help command
 func (*Help) Description() string {
 	return "Request a list of all game commands.\n"
 }
 
+// Params for Help command
+func (*Help) Params() []Param {
+	return []Param{{Name: "command", Type: "text", Optional: true}}
+}
+
+// Requirements for Help command
+func (*Help) Requirements() Requirements {
+	return Requirements{Uninstalled: true}
+}
+
+// Cooldown for Help command
+func (*Help) Cooldown() Cooldown {
+	return Cooldown{User: 5}
+}
+
 // LeaveServer command
 type LeaveServer struct{}
 
 // Execute method for LeaveServer command
-func (*LeaveServer) Execute(server *structure.Server, s *discordgo.Session, m *discordgo.MessageCreate) {
+func (*LeaveServer) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	Confirm(server, s, m, "Leave the game?", "All your progression in this server will be lost.", func(server *structure.Server, store db.Store, s *discordgo.Session) {
+		leaveGame(server, store, s, m)
+	})
+}
+
+// leaveGame removes the author of `m` from the game after they confirmed it
+func leaveGame(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate) {
 	// Check if user is playing
-	for _, user := range server.Users {
-		if user.ID == m.Author.ID {
-			err := db.RemoveServerUser(server, user)
-			if err != nil {
-				logger.Log.Error(err.Error())
-				return
-			}
-
-			// Create response message
-			message := &structure.Message{
-				Title:  "User " + m.Author.Username + " successfully removed from the server",
-				Type:   "system",
-				Icon:   "https://cdn.discordapp.com/attachments/512302843437252611/512302951814004752/ac6918be09a389876ee5663d6b08b55a.png",
-				Footer: "Command execution feedback.",
-			}
-
-			// Build Embed
-			embed := builder.BuildEmbed(message)
-
-			// Send response
-			_, err = s.ChannelMessageSendEmbed(m.ChannelID, embed)
-			if err != nil {
-				logger.Log.Error(err.Error())
-			}
-			return
+	user, err := store.GetServerUser(server, m.Author.ID)
+	if err != nil {
+		if err != db.NotFound {
+			logger.Log.Error(err.Error())
 		}
+		return
+	}
+	err = store.RemoveServerUser(server, user)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	// Remove Game Role from User
+	builder.ExpectRoleChange(server.ID, user.ID, user.Role)
+	err = s.GuildMemberRoleRemove(server.ID, user.ID, user.Role)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+
+	// Create response message
+	message := &structure.Message{
+		Title:  "User " + m.Author.Username + " successfully removed from the server",
+		Type:   "system",
+		Icon:   "https://cdn.discordapp.com/attachments/512302843437252611/512302951814004752/ac6918be09a389876ee5663d6b08b55a.png",
+		Footer: "Command execution feedback.",
+	}
+
+	// Build Embed
+	embed := builder.BuildEmbed(message)
+
+	// Send response
+	_, err = s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	if err != nil {
+		logger.Log.Error(err.Error())
 	}
 }
 
@@ -201,14 +308,97 @@ func (*LeaveServer) Trigger() string {
 	return "leaveGame"
 }
 
+// Aliases for LeaveServer command
+func (*LeaveServer) Aliases() []string {
+	return []string{"leave"}
+}
+
 // Description for LeaveServer command
 func (*LeaveServer) Description() string {
-	return "Removes the user from the game running on this server.\n**WARNING** Leaving the game will cause you to lose all your progression in the server!\n"
+	return "Removes the user from the game running on this server.\n**WARNING** Leaving the game will cause you to lose all your progression in the server!\nYou will be asked to confirm.\n"
+}
+
+// Params for LeaveServer command
+func (*LeaveServer) Params() []Param {
+	return nil
+}
+
+// Requirements for LeaveServer command
+func (*LeaveServer) Requirements() Requirements {
+	return Requirements{Player: true}
+}
+
+// Cooldown for LeaveServer command
+func (*LeaveServer) Cooldown() Cooldown {
+	return Cooldown{User: 10}
+}
+
+// ToggleDemotion command
+type ToggleDemotion struct{}
+
+// Execute method for ToggleDemotion command
+func (*ToggleDemotion) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	// Update Server object
+	err := db.Update(store, server, func(server *structure.Server) error {
+		server.Demotion = !server.Demotion
+		return store.UpdateServerDemotion(server)
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	if server.Demotion {
+		sendFeedback(s, m.ChannelID, "Inactive players will now be demoted.")
+	} else {
+		sendFeedback(s, m.ChannelID, "Inactive players will no longer be demoted.")
+	}
+}
+
+// Trigger for ToggleDemotion command
+func (*ToggleDemotion) Trigger() string {
+	return "toggleDemotion"
+}
+
+// Aliases for ToggleDemotion command
+func (*ToggleDemotion) Aliases() []string {
+	return nil
+}
+
+// Description for ToggleDemotion command
+func (*ToggleDemotion) Description() string {
+	return "Enable or disable demotion of players who have been inactive for too long.\nOnly the server's owner can execute this command.\n"
+}
+
+// Params for ToggleDemotion command
+func (*ToggleDemotion) Params() []Param {
+	return nil
+}
+
+// Requirements for ToggleDemotion command
+func (*ToggleDemotion) Requirements() Requirements {
+	return Requirements{Owner: true}
+}
+
+// Cooldown for ToggleDemotion command
+func (*ToggleDemotion) Cooldown() Cooldown {
+	return Cooldown{Guild: 600, Persist: true}
 }
 
 // MessageCommands array
 var MessageCommands = []Response{
 	&CloseGame{},
+	&ResetGame{},
+	&Uninstall{},
+	&Install{},
 	&Help{},
 	&LeaveServer{},
+	&ToggleDemotion{},
+	&PauseGame{},
+	&ResumeGame{},
+	&Admin{},
+	&Gather{},
+	&Gather{Resource: "r1"},
+	&Gather{Resource: "r2"},
+	&Gather{Resource: "r3"},
 }
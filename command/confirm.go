@@ -0,0 +1,166 @@
+package command
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/builder"
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// confirmationWindow is how long a confirmation waits for its requester
+const confirmationWindow = time.Minute
+
+// codeLetters are the letters used in typed confirmation codes
+const codeLetters = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// Confirmed is run once its requester confirms, with the Server object current at that time
+type Confirmed func(*structure.Server, db.Store, *discordgo.Session)
+
+// confirmation is a command waiting for its requester to confirm it
+type confirmation struct {
+	user    string
+	channel string
+	code    string
+	run     Confirmed
+}
+
+var confirmations = map[string]*confirmation{}
+var confirmationsMu sync.Mutex
+
+// Confirm asks the author of `m` to confirm `title` with a reaction or a typed code before running `run`
+func Confirm(server *structure.Server, s *discordgo.Session, m *discordgo.MessageCreate, title string, description string, run Confirmed) {
+	code := make([]byte, 4)
+	for i := range code {
+		code[i] = codeLetters[rand.Intn(len(codeLetters))]
+	}
+
+	// Create confirmation message
+	message := &structure.Message{
+		Title:       title,
+		Description: description + "\nReact with " + server.Actions["confirm"] + " or type `" + string(code) + "` to confirm, or react with " + server.Actions["cancel"] + " to cancel.",
+		Type:        "system",
+		Icon:        "https://cdn.discordapp.com/attachments/512302843437252611/512302951814004752/ac6918be09a389876ee5663d6b08b55a.png",
+		Footer:      "Expires in " + confirmationWindow.String() + ".",
+	}
+	ask(server, s, m.ChannelID, m.Author.ID, message, string(code), run)
+}
+
+// ask sends `message` to channel `channel` and waits for User `user` to confirm it before running `run`.
+// An empty `code` only allows confirming with a reaction
+func ask(server *structure.Server, s *discordgo.Session, channel string, user string, message *structure.Message, code string, run Confirmed) {
+	response, err := s.ChannelMessageSendEmbed(channel, builder.BuildEmbed(message))
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	for _, action := range []string{"confirm", "cancel"} {
+		err = s.MessageReactionAdd(channel, response.ID, server.Actions[action])
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+	}
+
+	// Remember confirmation
+	confirmationsMu.Lock()
+	confirmations[response.ID] = &confirmation{
+		user:    user,
+		channel: channel,
+		code:    code,
+		run:     run,
+	}
+	confirmationsMu.Unlock()
+
+	// Expire confirmation, outside the queue of the Guild as it only removes the reactions
+	time.AfterFunc(confirmationWindow, func() {
+		if take(response.ID, user) == nil {
+			return
+		}
+		err := s.MessageReactionsRemoveAll(channel, response.ID)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+	})
+}
+
+// take removes and returns the confirmation of message `id` if User `user` requested it
+func take(id string, user string) *confirmation {
+	confirmationsMu.Lock()
+	defer confirmationsMu.Unlock()
+	c, ok := confirmations[id]
+	if !ok || c.user != user {
+		return nil
+	}
+	delete(confirmations, id)
+	return c
+}
+
+// Answer runs the confirmation whose code the author of `m` typed, reporting whether there was one
+func Answer(store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, guild string) bool {
+	// Find confirmation
+	id := ""
+	confirmationsMu.Lock()
+	for key, c := range confirmations {
+		if c.code != "" && c.channel == m.ChannelID && c.user == m.Author.ID && strings.EqualFold(c.code, strings.TrimSpace(m.Content)) {
+			id = key
+			break
+		}
+	}
+	confirmationsMu.Unlock()
+	c := take(id, m.Author.ID)
+	if c == nil {
+		return false
+	}
+
+	// Get Server object
+	server, err := store.GetServer(guild)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return true
+	}
+	c.run(server, store, s)
+	return true
+}
+
+// Respond command structure, one per confirmation reaction
+type Respond struct {
+	Action string
+}
+
+// Execute method for Respond command
+func (r *Respond) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageReactionAdd) {
+	c := take(m.MessageID, m.UserID)
+	if c == nil {
+		return
+	}
+	err := s.MessageReactionsRemoveAll(m.ChannelID, m.MessageID)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+
+	if r.Action == "cancel" {
+		sendFeedback(s, m.ChannelID, "Cancelled.")
+		return
+	}
+	c.run(server, store, s)
+}
+
+// Trigger for Respond command
+func (r *Respond) Trigger() string {
+	return structure.DefaultServer.Actions[r.Action]
+}
+
+// Cooldown for Respond command
+func (r *Respond) Cooldown() Cooldown {
+	return Cooldown{}
+}
+
+// Targets for Respond command, confirmations are looked up when executing
+func (r *Respond) Targets(server *structure.Server, m *discordgo.MessageReactionAdd) bool {
+	return true
+}
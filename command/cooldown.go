@@ -0,0 +1,121 @@
+package command
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Cooldown describes how often a command may be executed, in seconds per User, per Server and globally.
+// Persisted cooldowns are stored so they survive restarts
+type Cooldown struct {
+	User    int
+	Guild   int
+	Global  int
+	Persist bool
+}
+
+var buckets = map[string]time.Time{}
+var bucketsMu sync.Mutex
+
+// Allow claims the cooldown buckets of command `name` for User `user`.
+// When a bucket is still running the User is told how long is left and false is returned
+func Allow(server *structure.Server, store db.Store, s *discordgo.Session, channel string, name string, user string, c Cooldown) bool {
+	keys, durations := bucketKeys(server.ID, name, user), bucketDurations(c)
+	left := time.Duration(0)
+	if c.Persist {
+		left = claimStored(store, keys, durations)
+	} else {
+		left = claim(keys, durations)
+	}
+	if left <= 0 {
+		return true
+	}
+
+	sendFeedback(s, channel, "You are doing that too fast. Try again in "+left.Round(time.Second).String()+".")
+	return false
+}
+
+// bucketKeys returns the global, Server and User bucket keys of command `name` for User `user` of Discord Guild `guild`, in claim order
+func bucketKeys(guild string, name string, user string) []string {
+	return []string{"global/" + name, guild + "/" + name, guild + "/" + name + "/" + user}
+}
+
+// bucketDurations returns the durations of the buckets of cooldown `c`, in the order of bucketKeys
+func bucketDurations(c Cooldown) []time.Duration {
+	return []time.Duration{
+		time.Duration(c.Global) * time.Second,
+		time.Duration(c.Guild) * time.Second,
+		time.Duration(c.User) * time.Second,
+	}
+}
+
+// claimStored starts every stored bucket of `keys` for its duration, otherwise returns the time left on the running one.
+// Buckets started before finding a running one are stopped again, so a refused attempt costs nothing
+func claimStored(store db.Store, keys []string, durations []time.Duration) time.Duration {
+	claimed := []string{}
+	for i, key := range keys {
+		if durations[i] <= 0 {
+			continue
+		}
+		left, err := store.ClaimCooldown(key, durations[i])
+		if err != nil {
+			logger.Log.Error(err.Error())
+			continue
+		}
+		if left <= 0 {
+			claimed = append(claimed, key)
+			continue
+		}
+
+		// Release claimed buckets
+		for _, c := range claimed {
+			err = store.ReleaseCooldown(c)
+			if err != nil {
+				logger.Log.Error(err.Error())
+			}
+		}
+		return left
+	}
+	return 0
+}
+
+// claim starts every bucket of `keys` for its duration if none of them is running, otherwise returns the longest time left
+func claim(keys []string, durations []time.Duration) time.Duration {
+	bucketsMu.Lock()
+	defer bucketsMu.Unlock()
+
+	// Check running buckets
+	now := time.Now()
+	left := time.Duration(0)
+	for i, key := range keys {
+		if durations[i] <= 0 {
+			continue
+		}
+		if expires, ok := buckets[key]; ok && expires.Sub(now) > left {
+			left = expires.Sub(now)
+		}
+	}
+	if left > 0 {
+		return left
+	}
+
+	// Forget expired buckets
+	for key, expires := range buckets {
+		if !expires.After(now) {
+			delete(buckets, key)
+		}
+	}
+
+	// Start buckets
+	for i, key := range keys {
+		if durations[i] > 0 {
+			buckets[key] = now.Add(durations[i])
+		}
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/db"
+)
+
+func TestClaim(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		c       Cooldown
+		guild   string
+		user    string
+		allowed bool
+	}{
+		// The first User of "g1" starts every bucket
+		{name: "first", command: "both", c: Cooldown{User: 60, Guild: 60}, guild: "g1", user: "u1", allowed: true},
+		{name: "same user", command: "both", c: Cooldown{User: 60, Guild: 60}, guild: "g1", user: "u1", allowed: false},
+		{name: "same guild", command: "both", c: Cooldown{User: 60, Guild: 60}, guild: "g1", user: "u2", allowed: false},
+		{name: "other guild", command: "both", c: Cooldown{User: 60, Guild: 60}, guild: "g2", user: "u1", allowed: true},
+
+		// A global bucket holds every Guild back
+		{name: "global first", command: "global", c: Cooldown{Global: 60}, guild: "g1", user: "u1", allowed: true},
+		{name: "global other guild", command: "global", c: Cooldown{Global: 60}, guild: "g2", user: "u2", allowed: false},
+
+		// A User bucket only holds the User back
+		{name: "user first", command: "user", c: Cooldown{User: 60}, guild: "g1", user: "u1", allowed: true},
+		{name: "user other user", command: "user", c: Cooldown{User: 60}, guild: "g1", user: "u2", allowed: true},
+		{name: "user again", command: "user", c: Cooldown{User: 60}, guild: "g1", user: "u1", allowed: false},
+
+		// Without durations nothing is held back
+		{name: "none first", command: "none", c: Cooldown{}, guild: "g1", user: "u1", allowed: true},
+		{name: "none again", command: "none", c: Cooldown{}, guild: "g1", user: "u1", allowed: true},
+	}
+	for _, test := range tests {
+		left := claim(bucketKeys(test.guild, test.command, test.user), bucketDurations(test.c))
+		if (left <= 0) != test.allowed {
+			t.Errorf("%s: expected allowed %v, got %v left", test.name, test.allowed, left)
+		}
+	}
+}
+
+func TestClaimStoredOrder(t *testing.T) {
+	store := db.NewMemoryStore()
+	c := Cooldown{Global: 60, Guild: 60, User: 60, Persist: true}
+
+	left := claimStored(store, bucketKeys("g1", "cmd", "u1"), bucketDurations(c))
+	if left > 0 {
+		t.Fatalf("expected first claim to be allowed, got %v left", left)
+	}
+
+	// The global bucket is checked first and refuses everyone
+	left = claimStored(store, bucketKeys("g2", "cmd", "u2"), bucketDurations(c))
+	if left <= 0 {
+		t.Fatal("expected the global bucket to refuse another Guild")
+	}
+}
+
+func TestClaimStoredRelease(t *testing.T) {
+	store := db.NewMemoryStore()
+
+	// Another User of the Guild runs the Guild bucket
+	left := claimStored(store, bucketKeys("g", "cmd", "u1"), bucketDurations(Cooldown{Guild: 60, Persist: true}))
+	if left > 0 {
+		t.Fatalf("expected first claim to be allowed, got %v left", left)
+	}
+
+	// The refused attempt claims the global bucket before finding the running Guild bucket, and releases it again
+	c := Cooldown{Global: 60, Guild: 60, User: 60, Persist: true}
+	left = claimStored(store, bucketKeys("g", "cmd", "u2"), bucketDurations(c))
+	if left <= 0 {
+		t.Fatal("expected the Guild bucket to refuse the claim")
+	}
+	for _, key := range []string{"global/cmd", "g/cmd/u2"} {
+		left, err := store.ClaimCooldown(key, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if left > 0 {
+			t.Errorf("expected bucket %s to be free after a refused claim, got %v left", key, left)
+		}
+	}
+}
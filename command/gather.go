@@ -0,0 +1,146 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/builder"
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/game"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Gather command structure, one per gatherable Resource.
+// Without a Resource the command gathers the Resource given as argument
+type Gather struct {
+	Resource string
+}
+
+// Execute method for Gather command
+func (g *Gather) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	key := g.Resource
+	if key == "" {
+		key = args.Get("resource")
+	}
+	resource, ok := server.Resources[key]
+	if !ok || resource.Gathering == nil {
+		return
+	}
+
+	// Find playing User
+	user, err := store.GetServerUser(server, m.Author.ID)
+	if err != nil {
+		if err != db.NotFound {
+			logger.Log.Error(err.Error())
+		}
+		return
+	}
+
+	// Check for the action Channel of the User's tier
+	roleKey := game.RoleKey(server, user.Role)
+	if roleKey == "" {
+		return
+	}
+	channel, ok := server.Channels["c"+strconv.Itoa(server.Roles[roleKey].Tier)+"action"]
+	if !ok || channel.ID != m.ChannelID {
+		sendFeedback(s, m.ChannelID, "You can only gather "+resource.Name+" in your tier's game card channel.")
+		return
+	}
+
+	// Check cooldown
+	cooldown := time.Duration(resource.Gathering.Cooldown) * time.Second
+	if last, ok := user.Gathered[key]; ok {
+		if remaining := cooldown - time.Since(time.Unix(last, 0)); remaining > 0 {
+			sendFeedback(s, m.ChannelID, "You are too tired to gather "+resource.Name+". Try again in "+remaining.Round(time.Second).String()+".")
+			return
+		}
+	}
+
+	// Update Server object
+	amount := resource.Gathering.Yield[roleKey]
+	gathered, err := store.GatherResource(server, user, key, amount, cooldown)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	if !gathered {
+		sendFeedback(s, m.ChannelID, "You are too tired to gather "+resource.Name+". Try again later.")
+		return
+	}
+
+	sendFeedback(s, m.ChannelID, fmt.Sprintf("%s gathered %d <%s> %s.", m.Author.Username, amount, resource.Icon, resource.Name))
+
+	// Check for promotion
+	user.Contribution += amount
+	game.UpdateTier(server, store, s, user)
+}
+
+// Trigger for Gather command
+func (g *Gather) Trigger() string {
+	if g.Resource == "" {
+		return "gather"
+	}
+	resource, ok := structure.DefaultServer.Resources[g.Resource]
+	if !ok || resource.Gathering == nil {
+		return ""
+	}
+	return resource.Gathering.Trigger
+}
+
+// Aliases for Gather command
+func (g *Gather) Aliases() []string {
+	return nil
+}
+
+// Description for Gather command
+func (g *Gather) Description() string {
+	if g.Resource == "" {
+		return "Gather the given resource for your server.\nCan only be used in your tier's game card channel.\n"
+	}
+	resource, ok := structure.DefaultServer.Resources[g.Resource]
+	if !ok {
+		return ""
+	}
+	return "Gather " + resource.Name + " for your server.\nCan only be used in your tier's game card channel.\n"
+}
+
+// Params for Gather command
+func (g *Gather) Params() []Param {
+	if g.Resource != "" {
+		return nil
+	}
+	return []Param{{Name: "resource", Type: "resource"}}
+}
+
+// Requirements for Gather command
+func (g *Gather) Requirements() Requirements {
+	return Requirements{Playing: true, Player: true, Tier: 1, Channels: []string{"action"}}
+}
+
+// Cooldown for Gather command
+func (g *Gather) Cooldown() Cooldown {
+	return Cooldown{User: 2}
+}
+
+// sendFeedback sends a system embed with `title` to channel `c`
+func sendFeedback(s *discordgo.Session, c string, title string) {
+	// Create response message
+	message := &structure.Message{
+		Title:  title,
+		Type:   "system",
+		Icon:   "https://cdn.discordapp.com/attachments/512302843437252611/512302951814004752/ac6918be09a389876ee5663d6b08b55a.png",
+		Footer: "Command execution feedback.",
+	}
+
+	// Build Embed
+	embed := builder.BuildEmbed(message)
+
+	// Send response
+	_, err := s.ChannelMessageSendEmbed(c, embed)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+}
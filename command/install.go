@@ -0,0 +1,187 @@
+package command
+
+import (
+	"github.com/Noxdew/Knights-Of-Discord/builder"
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/scheduler"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ResetGame command
+type ResetGame struct{}
+
+// Execute method for ResetGame command
+func (*ResetGame) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	Confirm(server, s, m, "Reset the game?", "All players, progress and resources of this server will be lost. Channels, roles and settings are kept.", func(server *structure.Server, store db.Store, s *discordgo.Session) {
+		g, err := s.Guild(server.ID)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			return
+		}
+		builder.ResetServer(server, store, s, g)
+		ForgetPrefix(server.ID)
+		sendFeedback(s, m.ChannelID, "Game reset.")
+	})
+}
+
+// Trigger for ResetGame command
+func (*ResetGame) Trigger() string {
+	return "resetGame"
+}
+
+// Aliases for ResetGame command
+func (*ResetGame) Aliases() []string {
+	return []string{"reset"}
+}
+
+// Description for ResetGame command
+func (*ResetGame) Description() string {
+	return "Start the game of this server over, keeping its channels, roles and settings.\nOnly the server's owner can execute this command.\n**WARNING!** Resetting the game will result in losing all progress and resources of your server!\nYou will be asked to confirm.\n"
+}
+
+// Params for ResetGame command
+func (*ResetGame) Params() []Param {
+	return nil
+}
+
+// Requirements for ResetGame command
+func (*ResetGame) Requirements() Requirements {
+	return Requirements{Owner: true}
+}
+
+// Cooldown for ResetGame command
+func (*ResetGame) Cooldown() Cooldown {
+	return Cooldown{Guild: 60}
+}
+
+// Uninstall command
+type Uninstall struct{}
+
+// Execute method for Uninstall command
+func (*Uninstall) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	Confirm(server, s, m, "Uninstall the game?", "All channels, roles, progress and resources of this server will be lost. The bot stays, so the game can be installed again.", func(server *structure.Server, store db.Store, s *discordgo.Session) {
+		g, err := s.Guild(server.ID)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			return
+		}
+
+		// Stop periodic game tasks
+		err = scheduler.Unschedule(server, store)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+		builder.UninstallServer(server, store, s, g)
+		ForgetPrefix(server.ID)
+
+		sendFeedback(s, m.ChannelID, "Game uninstalled. Use `"+Prefix(server)+"install` to install it again.")
+	})
+}
+
+// Trigger for Uninstall command
+func (*Uninstall) Trigger() string {
+	return "uninstall"
+}
+
+// Aliases for Uninstall command
+func (*Uninstall) Aliases() []string {
+	return nil
+}
+
+// Description for Uninstall command
+func (*Uninstall) Description() string {
+	return "Remove the game's channels and roles from this server, keeping the bot so the game can be installed again.\nOnly the server's owner can execute this command.\n**WARNING!** Uninstalling the game will result in losing all progress and resources of your server!\nYou will be asked to confirm.\n"
+}
+
+// Params for Uninstall command
+func (*Uninstall) Params() []Param {
+	return nil
+}
+
+// Requirements for Uninstall command
+func (*Uninstall) Requirements() Requirements {
+	return Requirements{Owner: true}
+}
+
+// Cooldown for Uninstall command
+func (*Uninstall) Cooldown() Cooldown {
+	return Cooldown{Guild: 60}
+}
+
+// Install command
+type Install struct{}
+
+// Execute method for Install command
+func (*Install) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	if !server.Uninstalled {
+		sendFeedback(s, m.ChannelID, "The game is already installed on this server.")
+		return
+	}
+	g, err := s.Guild(server.ID)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	// Replace uninstalled Server with a fresh one, keeping its settings
+	err = store.DeleteServer(server)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	defer ForgetPrefix(server.ID)
+	fresh := structure.DefaultServer.Copy()
+	builder.KeepSettings(server, &fresh)
+
+	// Build game
+	err = builder.BuildServer(&fresh, store, s, g)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		err = builder.MarkUninstalled(server, store)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+		sendFeedback(s, m.ChannelID, "The game could not be installed. Check the bot's permissions and try again.")
+		return
+	}
+
+	// Start periodic game tasks
+	err = scheduler.Schedule(&fresh, store)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+
+	sendFeedback(s, m.ChannelID, "Game installed.")
+}
+
+// Trigger for Install command
+func (*Install) Trigger() string {
+	return "install"
+}
+
+// Aliases for Install command
+func (*Install) Aliases() []string {
+	return nil
+}
+
+// Description for Install command
+func (*Install) Description() string {
+	return "Install the game again on this server after it was uninstalled.\nOnly the server's owner and administrators can execute this command.\n"
+}
+
+// Params for Install command
+func (*Install) Params() []Param {
+	return nil
+}
+
+// Requirements for Install command
+func (*Install) Requirements() Requirements {
+	return Requirements{Admin: true, Uninstalled: true}
+}
+
+// Cooldown for Install command
+func (*Install) Cooldown() Cooldown {
+	return Cooldown{Guild: 60}
+}
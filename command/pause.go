@@ -0,0 +1,128 @@
+package command
+
+import (
+	"github.com/Noxdew/Knights-Of-Discord/builder"
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/scheduler"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// PauseGame command
+type PauseGame struct{}
+
+// Execute method for PauseGame command
+func (*PauseGame) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	if server.Paused {
+		sendFeedback(s, m.ChannelID, "The game is already paused.")
+		return
+	}
+	if !server.Playing {
+		sendFeedback(s, m.ChannelID, "The game is not running, it can not be paused.")
+		return
+	}
+	g, err := s.Guild(server.ID)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	// Stop periodic game tasks
+	err = scheduler.Unschedule(server, store)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+	builder.PauseServer(server, store, s, g)
+
+	sendFeedback(s, m.ChannelID, "Game paused.")
+}
+
+// Trigger for PauseGame command
+func (*PauseGame) Trigger() string {
+	return "pauseGame"
+}
+
+// Aliases for PauseGame command
+func (*PauseGame) Aliases() []string {
+	return []string{"pause"}
+}
+
+// Description for PauseGame command
+func (*PauseGame) Description() string {
+	return "Pause the game running on this server, locking its action channels.\nOnly the server's owner and administrators can execute this command.\n"
+}
+
+// Params for PauseGame command
+func (*PauseGame) Params() []Param {
+	return nil
+}
+
+// Requirements for PauseGame command
+func (*PauseGame) Requirements() Requirements {
+	return Requirements{Admin: true}
+}
+
+// Cooldown for PauseGame command
+func (*PauseGame) Cooldown() Cooldown {
+	return Cooldown{Guild: 30}
+}
+
+// ResumeGame command
+type ResumeGame struct{}
+
+// Execute method for ResumeGame command
+func (*ResumeGame) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	if server.Playing {
+		sendFeedback(s, m.ChannelID, "The game is already running.")
+		return
+	}
+	if !server.Paused || server.Building {
+		sendFeedback(s, m.ChannelID, "The game is not paused, it can not be resumed.")
+		return
+	}
+	g, err := s.Guild(server.ID)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	builder.ResumeServer(server, store, s, g)
+
+	// Start periodic game tasks
+	err = scheduler.Schedule(server, store)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+
+	sendFeedback(s, m.ChannelID, "Game resumed.")
+}
+
+// Trigger for ResumeGame command
+func (*ResumeGame) Trigger() string {
+	return "resumeGame"
+}
+
+// Aliases for ResumeGame command
+func (*ResumeGame) Aliases() []string {
+	return []string{"resume"}
+}
+
+// Description for ResumeGame command
+func (*ResumeGame) Description() string {
+	return "Resume the paused game of this server.\nOnly the server's owner and administrators can execute this command.\n"
+}
+
+// Params for ResumeGame command
+func (*ResumeGame) Params() []Param {
+	return nil
+}
+
+// Requirements for ResumeGame command
+func (*ResumeGame) Requirements() Requirements {
+	return Requirements{Admin: true}
+}
+
+// Cooldown for ResumeGame command
+func (*ResumeGame) Cooldown() Cooldown {
+	return Cooldown{Guild: 30}
+}
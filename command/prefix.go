@@ -0,0 +1,116 @@
+package command
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/Noxdew/Knights-Of-Discord/config"
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxPrefix is the maximum length of a Server command prefix
+const maxPrefix = 16
+
+// prefixes caches the command prefix of every Server by Guild ID, so messages without commands cost no database round-trip
+var prefixes sync.Map
+
+// Prefix returns the command prefix of `server`
+func Prefix(server *structure.Server) string {
+	if server.Prefix != "" {
+		return server.Prefix
+	}
+	return config.Get().Prefix
+}
+
+// ForgetPrefix drops the cached command prefix of guild `guild`, after its Server was replaced or deleted
+func ForgetPrefix(guild string) {
+	prefixes.Delete(guild)
+}
+
+// Match returns the content of message `content` sent in guild `guild` after the command prefix or a mention of the bot,
+// reporting whether the message is a command
+func Match(store db.Store, s *discordgo.Session, guild string, content string) (string, bool) {
+	// Mentions of the bot always work
+	if s.State.User != nil {
+		for _, mention := range []string{"<@" + s.State.User.ID + ">", "<@!" + s.State.User.ID + ">"} {
+			if strings.HasPrefix(content, mention) {
+				return strings.TrimSpace(strings.TrimPrefix(content, mention)), true
+			}
+		}
+	}
+
+	// Load Server prefix
+	prefix, ok := prefixes.Load(guild)
+	if !ok {
+		server, err := store.GetServer(guild)
+		if err != nil && err != db.NotFound {
+			logger.Log.Error(err.Error())
+			return "", false
+		}
+		prefix = Prefix(server)
+		prefixes.Store(guild, prefix)
+	}
+
+	if !strings.HasPrefix(content, prefix.(string)) {
+		return "", false
+	}
+	return strings.TrimPrefix(content, prefix.(string)), true
+}
+
+// SetPrefix command
+type SetPrefix struct{}
+
+// Execute method for SetPrefix command
+func (*SetPrefix) Execute(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, args Args) {
+	prefix := args.Get("prefix")
+	if len(prefix) > maxPrefix {
+		sendFeedback(s, m.ChannelID, "The prefix can not be longer than 16 characters.")
+		return
+	}
+
+	// Update Server object
+	err := db.Update(store, server, func(server *structure.Server) error {
+		server.Prefix = prefix
+		return store.UpdateServerPrefix(server)
+	})
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	prefixes.Store(server.ID, Prefix(server))
+
+	sendFeedback(s, m.ChannelID, "Commands now start with `"+Prefix(server)+"`. Mentioning the bot works as well.")
+}
+
+// Trigger for SetPrefix command
+func (*SetPrefix) Trigger() string {
+	return "prefix"
+}
+
+// Aliases for SetPrefix command
+func (*SetPrefix) Aliases() []string {
+	return nil
+}
+
+// Description for SetPrefix command
+func (*SetPrefix) Description() string {
+	return "Change the command prefix of this server, or reset it to the default one.\nOnly the server's owner and administrators can execute this command.\n"
+}
+
+// Params for SetPrefix command
+func (*SetPrefix) Params() []Param {
+	return []Param{{Name: "prefix", Type: "string", Optional: true}}
+}
+
+// Requirements for SetPrefix command
+func (*SetPrefix) Requirements() Requirements {
+	return Requirements{Admin: true, Uninstalled: true}
+}
+
+// Cooldown for SetPrefix command
+func (*SetPrefix) Cooldown() Cooldown {
+	return Cooldown{Guild: 5}
+}
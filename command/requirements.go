@@ -0,0 +1,112 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/game"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Requirements describes who may execute a message command, where, and whether the game has to be running.
+// Channels lists the allowed game Channel types: "action", "social", "announcement" or "rules".
+// Uninstalled allows the command on servers where the game is not installed
+type Requirements struct {
+	Playing     bool
+	Uninstalled bool
+	Owner       bool
+	Admin       bool
+	Player      bool
+	Tier        int
+	Channels    []string
+}
+
+// refusal checks Requirements `r` of message command `name` for the author of `m`, returning why they may not execute it or an empty string
+func refusal(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, name string, r Requirements) string {
+	// Check installed game
+	if server.Uninstalled && !r.Uninstalled {
+		return "The game is not installed on this server. Use `" + Prefix(server) + "install` to install it."
+	}
+
+	// Check running game
+	if r.Playing && !server.Playing {
+		return "The game is paused, `" + name + "` can not be used until it resumes."
+	}
+
+	// Check Guild owner and administrators
+	if r.Owner || r.Admin {
+		g, err := s.Guild(server.ID)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			return "`" + name + "` is not available right now."
+		}
+		if g.OwnerID != m.Author.ID {
+			if r.Owner {
+				return "Only the server's owner can use `" + name + "`."
+			}
+			if !isAdmin(s, g, m.Author.ID) {
+				return "Only the server's owner and administrators can use `" + name + "`."
+			}
+		}
+	}
+
+	// Check player and game tier
+	if r.Player || r.Tier > 0 {
+		user, err := store.GetServerUser(server, m.Author.ID)
+		if err == db.NotFound {
+			return "You have to join the game before using `" + name + "`."
+		}
+		if err != nil {
+			logger.Log.Error(err.Error())
+			return "`" + name + "` is not available right now."
+		}
+		key := game.RoleKey(server, user.Role)
+		if r.Tier > 0 && (key == "" || server.Roles[key].Tier < r.Tier) {
+			for _, role := range server.Roles {
+				if role.Tier == r.Tier {
+					return "You have to reach " + role.DefaultName + " before using `" + name + "`."
+				}
+			}
+			return "Your game role can not use `" + name + "`."
+		}
+	}
+
+	// Check Channel type
+	if len(r.Channels) > 0 {
+		kind := ""
+		for key, channel := range server.Channels {
+			if channel.ID == m.ChannelID {
+				kind = channel.Type
+				if key == "rules" {
+					kind = "rules"
+				}
+				break
+			}
+		}
+		for _, allowed := range r.Channels {
+			if allowed == kind {
+				return ""
+			}
+		}
+		return "`" + name + "` can only be used in " + strings.Join(r.Channels, " or ") + " game channels."
+	}
+	return ""
+}
+
+// isAdmin reports whether member `user` of guild `g` has a Role with Administrator permission
+func isAdmin(s *discordgo.Session, g *discordgo.Guild, user string) bool {
+	member, err := s.GuildMember(g.ID, user)
+	if err != nil {
+		return false
+	}
+	for _, id := range member.Roles {
+		for _, role := range g.Roles {
+			if role.ID == id && role.Permissions&discordgo.PermissionAdministrator != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,287 @@
+package command
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Noxdew/Knights-Of-Discord/builder"
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Param describes an argument of a message command.
+// Type is one of "user", "channel", "role", "int", "resource", "string" or "text", which takes the rest of the message
+type Param struct {
+	Name     string
+	Type     string
+	Optional bool
+	Variadic bool
+}
+
+// Args contains the parsed arguments of a message command by Param name
+type Args map[string][]string
+
+// Get returns the first value of argument `name`, or an empty string
+func (a Args) Get(name string) string {
+	if len(a[name]) == 0 {
+		return ""
+	}
+	return a[name][0]
+}
+
+// Int returns the first value of integer argument `name`, or 0
+func (a Args) Int(name string) int {
+	n, _ := strconv.Atoi(a.Get(name))
+	return n
+}
+
+// Group interface for message commands with subcommands
+type Group interface {
+	Subcommands() []Response
+}
+
+var mentions = map[string]*regexp.Regexp{
+	"user":    regexp.MustCompile(`^(?:<@!?(\d+)>|(\d+))$`),
+	"channel": regexp.MustCompile(`^(?:<#(\d+)>|(\d+))$`),
+	"role":    regexp.MustCompile(`^(?:<@&(\d+)>|(\d+))$`),
+}
+
+// Route parses `line`, a message without the command prefix, and executes the matching message command
+func Route(server *structure.Server, store db.Store, s *discordgo.Session, m *discordgo.MessageCreate, line string) {
+	tokens, err := tokenize(line)
+	if err != nil {
+		sendFeedback(s, m.ChannelID, err.Error())
+		return
+	}
+
+	// Find command
+	path, cmd, tokens := resolve(MessageCommands, tokens)
+	if cmd == nil {
+		sendUnknown(server, s, m, line)
+		return
+	}
+
+	// Check requirements
+	if reason := refusal(server, store, s, m, strings.Join(path, " "), cmd.Requirements()); reason != "" {
+		sendFeedback(s, m.ChannelID, reason)
+		return
+	}
+
+	// Parse arguments
+	args, err := parse(server, cmd.Params(), tokens)
+	if err != nil {
+		sendUsage(server, s, m.ChannelID, err.Error(), path, cmd)
+		return
+	}
+
+	// Check cooldowns
+	if !Allow(server, store, s, m.ChannelID, strings.Join(path, " "), m.Author.ID, cmd.Cooldown()) {
+		return
+	}
+
+	cmd.Execute(server, store, s, m, args)
+}
+
+// Usage returns the usage string of message command `cmd` reached through triggers `path` on `server`
+func Usage(server *structure.Server, path []string, cmd Response) string {
+	usage := Prefix(server) + strings.Join(path, " ")
+	for _, p := range cmd.Params() {
+		name := p.Name
+		if p.Variadic {
+			name += "..."
+		}
+		if p.Optional {
+			usage += " [" + name + "]"
+		} else {
+			usage += " <" + name + ">"
+		}
+	}
+	if _, ok := cmd.(Group); ok {
+		usage += " <subcommand>"
+	}
+	return usage
+}
+
+// resolve finds the message command named by the first `tokens`, returning its trigger path and the remaining tokens
+func resolve(commands []Response, tokens []string) ([]string, Response, []string) {
+	if len(tokens) == 0 {
+		return nil, nil, tokens
+	}
+	cmd := find(commands, tokens[0])
+	if cmd == nil {
+		return nil, nil, tokens
+	}
+	path := []string{cmd.Trigger()}
+	tokens = tokens[1:]
+
+	// Find subcommand
+	for len(tokens) > 0 {
+		group, ok := cmd.(Group)
+		if !ok {
+			break
+		}
+		sub := find(group.Subcommands(), tokens[0])
+		if sub == nil {
+			break
+		}
+		cmd = sub
+		path = append(path, sub.Trigger())
+		tokens = tokens[1:]
+	}
+	return path, cmd, tokens
+}
+
+// find returns the command of `commands` with trigger or alias `name`
+func find(commands []Response, name string) Response {
+	for _, cmd := range commands {
+		if cmd.Trigger() == name {
+			return cmd
+		}
+		for _, alias := range cmd.Aliases() {
+			if alias == name {
+				return cmd
+			}
+		}
+	}
+	return nil
+}
+
+// tokenize splits `line` on spaces, keeping quoted text together
+func tokenize(line string) ([]string, error) {
+	tokens := []string{}
+	current := ""
+	quoted, started := false, false
+	for _, r := range line {
+		switch {
+		case r == '"' || r == '“' || r == '”':
+			quoted = !quoted
+			started = true
+		case !quoted && (r == ' ' || r == '\n' || r == '\t'):
+			if started {
+				tokens = append(tokens, current)
+			}
+			current, started = "", false
+		default:
+			current += string(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("Missing closing quote in command.")
+	}
+	if started {
+		tokens = append(tokens, current)
+	}
+	return tokens, nil
+}
+
+// parse matches `tokens` to `params`, checking the type of every argument
+func parse(server *structure.Server, params []Param, tokens []string) (Args, error) {
+	args := Args{}
+	for _, p := range params {
+		if len(tokens) == 0 {
+			if !p.Optional {
+				return nil, errors.New("Missing argument `" + p.Name + "`.")
+			}
+			continue
+		}
+
+		// Text takes the rest of the message
+		if p.Type == "text" {
+			args[p.Name] = []string{strings.Join(tokens, " ")}
+			tokens = nil
+			continue
+		}
+
+		count := 1
+		if p.Variadic {
+			count = len(tokens)
+		}
+		for _, token := range tokens[:count] {
+			value, err := convert(server, p, token)
+			if err != nil {
+				return nil, err
+			}
+			args[p.Name] = append(args[p.Name], value)
+		}
+		tokens = tokens[count:]
+	}
+	if len(tokens) > 0 {
+		return nil, errors.New("Too many arguments: `" + strings.Join(tokens, " ") + "`.")
+	}
+	return args, nil
+}
+
+// convert checks `token` against the type of `p`, returning the Discord ID, number or Resource key it names
+func convert(server *structure.Server, p Param, token string) (string, error) {
+	switch p.Type {
+	case "user", "channel", "role":
+		match := mentions[p.Type].FindStringSubmatch(token)
+		if match == nil {
+			return "", errors.New("`" + token + "` is not a valid " + p.Type + " for `" + p.Name + "`.")
+		}
+		return match[1] + match[2], nil
+	case "int":
+		_, err := strconv.Atoi(token)
+		if err != nil {
+			return "", errors.New("`" + token + "` is not a whole number for `" + p.Name + "`.")
+		}
+		return token, nil
+	case "resource":
+		for key, resource := range server.Resources {
+			if key == token || strings.EqualFold(resource.Name, token) {
+				return key, nil
+			}
+		}
+		return "", errors.New("`" + token + "` is not a resource for `" + p.Name + "`.")
+	}
+	return token, nil
+}
+
+// usageFields returns the usage, aliases and subcommands of message command `cmd` as embed fields
+func usageFields(server *structure.Server, path []string, cmd Response) []*structure.Field {
+	fields := []*structure.Field{{
+		Title: "Usage",
+		Value: "`" + Usage(server, path, cmd) + "`",
+	}}
+	if len(cmd.Aliases()) > 0 {
+		fields = append(fields, &structure.Field{
+			Title: "Aliases",
+			Value: "`" + strings.Join(cmd.Aliases(), "`, `") + "`",
+		})
+	}
+	if group, ok := cmd.(Group); ok {
+		for _, sub := range group.Subcommands() {
+			fields = append(fields, &structure.Field{
+				Title: "`" + Usage(server, append(path[:len(path):len(path)], sub.Trigger()), sub) + "`",
+				Value: sub.Description(),
+			})
+		}
+	}
+	return fields
+}
+
+// sendUsage sends a system embed with `title` and the usage of message command `cmd` to channel `c`
+func sendUsage(server *structure.Server, s *discordgo.Session, c string, title string, path []string, cmd Response) {
+	// Create response message
+	message := &structure.Message{
+		Title:  title,
+		Type:   "system",
+		Icon:   "https://cdn.discordapp.com/attachments/512302843437252611/512302951814004752/ac6918be09a389876ee5663d6b08b55a.png",
+		Footer: "Command execution feedback.",
+		Fields: usageFields(server, path, cmd),
+	}
+
+	// Build Embed
+	embed := builder.BuildEmbed(message)
+
+	// Send response
+	_, err := s.ChannelMessageSendEmbed(c, embed)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+}
@@ -0,0 +1,168 @@
+package command
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line   string
+		tokens []string
+		err    bool
+	}{
+		{line: "", tokens: []string{}},
+		{line: "gather wood", tokens: []string{"gather", "wood"}},
+		{line: "  gather \t wood\n", tokens: []string{"gather", "wood"}},
+		{line: `prefix "! "`, tokens: []string{"prefix", "! "}},
+		{line: `say “hello there”`, tokens: []string{"say", "hello there"}},
+		{line: `prefix ""`, tokens: []string{"prefix", ""}},
+		{line: `a"b c"d`, tokens: []string{"ab cd"}},
+		{line: `prefix "!`, err: true},
+	}
+	for _, test := range tests {
+		tokens, err := tokenize(test.line)
+		if test.err {
+			if err == nil {
+				t.Errorf("tokenize(%q): expected an error, got %q", test.line, tokens)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("tokenize(%q): unexpected error %v", test.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(tokens, test.tokens) {
+			t.Errorf("tokenize(%q): expected %q, got %q", test.line, test.tokens, tokens)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	server := &structure.Server{Resources: map[string]*structure.Resource{"r1": {Name: "Wood"}}}
+	tests := []struct {
+		name   string
+		params []Param
+		tokens []string
+		args   Args
+		err    string
+	}{
+		{
+			name:   "user mention",
+			params: []Param{{Name: "user", Type: "user"}},
+			tokens: []string{"<@!123>"},
+			args:   Args{"user": {"123"}},
+		},
+		{
+			name:   "raw IDs",
+			params: []Param{{Name: "channel", Type: "channel"}, {Name: "role", Type: "role"}},
+			tokens: []string{"456", "<@&789>"},
+			args:   Args{"channel": {"456"}, "role": {"789"}},
+		},
+		{
+			name:   "bad mention",
+			params: []Param{{Name: "role", Type: "role"}},
+			tokens: []string{"<#123>"},
+			err:    "is not a valid role",
+		},
+		{
+			name:   "missing required parameter",
+			params: []Param{{Name: "user", Type: "user"}},
+			tokens: []string{},
+			err:    "Missing argument `user`",
+		},
+		{
+			name:   "missing optional parameter",
+			params: []Param{{Name: "prefix", Type: "string", Optional: true}},
+			tokens: []string{},
+			args:   Args{},
+		},
+		{
+			name:   "extra argument",
+			params: []Param{{Name: "amount", Type: "int"}},
+			tokens: []string{"3", "4"},
+			err:    "Too many arguments: `4`",
+		},
+		{
+			name:   "not a number",
+			params: []Param{{Name: "amount", Type: "int"}},
+			tokens: []string{"three"},
+			err:    "is not a whole number",
+		},
+		{
+			name:   "variadic",
+			params: []Param{{Name: "roles", Type: "role", Variadic: true}},
+			tokens: []string{"<@&1>", "2", "<@&3>"},
+			args:   Args{"roles": {"1", "2", "3"}},
+		},
+		{
+			name:   "variadic with a bad value",
+			params: []Param{{Name: "roles", Type: "role", Variadic: true}},
+			tokens: []string{"<@&1>", "everyone"},
+			err:    "`everyone` is not a valid role",
+		},
+		{
+			name:   "resource by key and by name",
+			params: []Param{{Name: "first", Type: "resource"}, {Name: "second", Type: "resource"}},
+			tokens: []string{"r1", "wood"},
+			args:   Args{"first": {"r1"}, "second": {"r1"}},
+		},
+		{
+			name:   "unknown resource",
+			params: []Param{{Name: "resource", Type: "resource"}},
+			tokens: []string{"stone"},
+			err:    "is not a resource",
+		},
+		{
+			name:   "text takes the rest",
+			params: []Param{{Name: "user", Type: "user"}, {Name: "reason", Type: "text"}},
+			tokens: []string{"1", "too", "loud"},
+			args:   Args{"user": {"1"}, "reason": {"too loud"}},
+		},
+	}
+	for _, test := range tests {
+		args, err := parse(server, test.params, test.tokens)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.args, args)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	commands := []Response{&Help{}, &Admin{}}
+	tests := []struct {
+		tokens []string
+		path   []string
+		rest   []string
+	}{
+		{tokens: []string{"help"}, path: []string{"help"}, rest: []string{}},
+		{tokens: []string{"commands", "admin"}, path: []string{"help"}, rest: []string{"admin"}},
+		{tokens: []string{"admin", "prefix", "!"}, path: []string{"admin", "prefix"}, rest: []string{"!"}},
+		{tokens: []string{"admin", "unknown"}, path: []string{"admin"}, rest: []string{"unknown"}},
+		{tokens: []string{"unknown"}, rest: []string{"unknown"}},
+		{tokens: []string{}, rest: []string{}},
+	}
+	for _, test := range tests {
+		path, cmd, rest := resolve(commands, test.tokens)
+		if (cmd == nil) != (test.path == nil) {
+			t.Errorf("resolve(%q): expected path %q, got command %v", test.tokens, test.path, cmd)
+			continue
+		}
+		if !reflect.DeepEqual(path, test.path) || !reflect.DeepEqual(rest, test.rest) {
+			t.Errorf("resolve(%q): expected %q and %q, got %q and %q", test.tokens, test.path, test.rest, path, rest)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// suggest returns the trigger or alias of `commands` closest to `name`, or an empty string if none is close enough
+func suggest(commands []Response, name string) string {
+	best, bestDistance := "", len(name)/3+1
+	if bestDistance < 2 {
+		bestDistance = 2
+	}
+	for _, cmd := range commands {
+		for _, candidate := range append([]string{cmd.Trigger()}, cmd.Aliases()...) {
+			if candidate == "" {
+				continue
+			}
+			d := distance(strings.ToLower(name), strings.ToLower(candidate))
+			if d <= bestDistance && (best == "" || d < bestDistance) {
+				best, bestDistance = candidate, d
+			}
+		}
+	}
+	return best
+}
+
+// distance returns the Levenshtein edit distance between `a` and `b`
+func distance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current := make([]int, len(rb)+1)
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minimum(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(rb)]
+}
+
+func minimum(values ...int) int {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v < result {
+			result = v
+		}
+	}
+	return result
+}
+
+// sendUnknown tells the author of `m` that command line `line` is unknown, offering to run the closest command instead
+func sendUnknown(server *structure.Server, s *discordgo.Session, m *discordgo.MessageCreate, line string) {
+	fields := strings.Fields(line)
+	name := ""
+	if len(fields) > 0 {
+		name = fields[0]
+	}
+	match := suggest(MessageCommands, name)
+	if match == "" {
+		sendFeedback(s, m.ChannelID, "Unknown command. Try using `"+Prefix(server)+"help` for a list of all game commands.")
+		return
+	}
+	corrected := match + strings.TrimPrefix(strings.TrimSpace(line), name)
+
+	// Create response message
+	message := &structure.Message{
+		Title:       "Unknown command. Did you mean `" + Prefix(server) + corrected + "`?",
+		Description: "React with " + server.Actions["confirm"] + " to run it, or with " + server.Actions["cancel"] + " to dismiss it.",
+		Type:        "system",
+		Icon:        "https://cdn.discordapp.com/attachments/512302843437252611/512302951814004752/ac6918be09a389876ee5663d6b08b55a.png",
+		Footer:      "Command execution feedback.",
+	}
+
+	// Run corrected command once confirmed
+	ask(server, s, m.ChannelID, m.Author.ID, message, "", func(server *structure.Server, store db.Store, s *discordgo.Session) {
+		Route(server, store, s, m, corrected)
+	})
+}
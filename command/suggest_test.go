@@ -0,0 +1,89 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// testCommand is a message command with a fixed trigger, aliases and subcommands
+type testCommand struct {
+	trigger string
+	aliases []string
+	subs    []Response
+}
+
+func (c *testCommand) Trigger() string            { return c.trigger }
+func (c *testCommand) Aliases() []string          { return c.aliases }
+func (c *testCommand) Description() string        { return "" }
+func (c *testCommand) Params() []Param            { return nil }
+func (c *testCommand) Requirements() Requirements { return Requirements{} }
+func (c *testCommand) Cooldown() Cooldown         { return Cooldown{} }
+func (c *testCommand) Subcommands() []Response    { return c.subs }
+func (c *testCommand) Execute(*structure.Server, db.Store, *discordgo.Session, *discordgo.MessageCreate, Args) {
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"help", "help", 0},
+		{"", "help", 4},
+		{"hlep", "help", 2},
+		{"hepl", "help", 2},
+		{"hel", "help", 1},
+		{"helpp", "help", 1},
+		{"kelp", "help", 1},
+		{"gäther", "gather", 1},
+	}
+	for _, test := range tests {
+		if d := distance(test.a, test.b); d != test.distance {
+			t.Errorf("distance(%q, %q): expected %d, got %d", test.a, test.b, test.distance, d)
+		}
+		if d := distance(test.b, test.a); d != test.distance {
+			t.Errorf("distance(%q, %q): expected %d, got %d", test.b, test.a, test.distance, d)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	commands := []Response{
+		&testCommand{trigger: "help", aliases: []string{"commands"}},
+		&testCommand{trigger: "hello"},
+		&testCommand{trigger: "toggleDemotion"},
+		&testCommand{trigger: "admin", subs: []Response{&testCommand{trigger: "prefix"}}},
+		&testCommand{trigger: "gather", aliases: []string{"chop"}},
+		&testCommand{trigger: "chip"},
+	}
+	tests := []struct {
+		name    string
+		suggest string
+	}{
+		{"hepl", "help"},
+		{"HELP", "help"},
+		{"comands", "commands"},
+		{"togledemotoin", "toggleDemotion"},
+
+		// Short names allow two edits, longer ones a third of their length plus one
+		{"hexx", "help"},
+		{"xyz", ""},
+		{"togglexxxxxxxx", ""},
+
+		// Ties go to the first command, whether its trigger or an alias matches
+		{"helo", "help"},
+		{"chep", "chop"},
+
+		// Subcommands are only suggested through their group
+		{"prefx", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if s := suggest(commands, test.name); s != test.suggest {
+			t.Errorf("suggest(%q): expected %q, got %q", test.name, test.suggest, s)
+		}
+	}
+}
@@ -9,11 +9,19 @@ import (
 
 // Definition defines the structure of a config for this application
 type Definition struct {
-	Token      string `json:"Token"`
-	Prefix     string `json:"Prefix"`
-	DBUrl      string `json:"DBUrl"`
-	DBUser     string `json:"DBUser"`
-	DBPassword string `json:"DBPassword"`
+	Token            string `json:"Token"`
+	Prefix           string `json:"Prefix"`
+	DBUrl            string `json:"DBUrl"`
+	DBUser           string `json:"DBUser"`
+	DBPassword       string `json:"DBPassword"`
+	DBPoolSize       int    `json:"DBPoolSize"`
+	DBConnectTimeout int    `json:"DBConnectTimeout"`
+	DBTimeout        int    `json:"DBTimeout"`
+	ArchiveGrace     int    `json:"ArchiveGrace"`
+	RejoinWindow     int    `json:"RejoinWindow"`
+	RolloutBatch     int    `json:"RolloutBatch"`
+	QueueSize        int    `json:"QueueSize"`
+	QueueReport      int    `json:"QueueReport"`
 }
 
 // Config contains the configuration of this application
@@ -40,5 +48,31 @@ func Load() {
 		logger.Log.Panic(err.Error())
 	}
 
+	// Set defaults for optional values
+	if config.DBPoolSize <= 0 {
+		config.DBPoolSize = 16
+	}
+	if config.DBConnectTimeout <= 0 {
+		config.DBConnectTimeout = 10
+	}
+	if config.DBTimeout <= 0 {
+		config.DBTimeout = 5
+	}
+	if config.ArchiveGrace <= 0 {
+		config.ArchiveGrace = 720
+	}
+	if config.RejoinWindow <= 0 {
+		config.RejoinWindow = 168
+	}
+	if config.RolloutBatch <= 0 {
+		config.RolloutBatch = 5
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 64
+	}
+	if config.QueueReport <= 0 {
+		config.QueueReport = 10
+	}
+
 	logger.Log.Info("Config loaded")
 }
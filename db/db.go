@@ -1,113 +1,187 @@
 package db
 
 import (
-	"context"
+	"errors"
+	"time"
 
-	"github.com/Noxdew/Knights-Of-Discord/config"
-	"github.com/Noxdew/Knights-Of-Discord/logger"
 	"github.com/Noxdew/Knights-Of-Discord/structure"
-	"github.com/mongodb/mongo-go-driver/bson"
 	"github.com/mongodb/mongo-go-driver/mongo"
 )
 
 // NotFound represents empty query results
 var NotFound = mongo.ErrNoDocuments
 
-func connect() *mongo.Client {
-	client, err := mongo.NewClient("mongodb://" + config.Get().DBUser + ":" + config.Get().DBPassword + "@" + config.Get().DBUrl)
-	if err != nil {
-		logger.Log.Panic(err)
-	}
-	err = client.Connect(context.Background())
-	if err != nil {
-		logger.Log.Panic(err)
+// Conflict is returned when a Server object was changed by someone else since it was loaded
+var Conflict = errors.New("server was changed concurrently")
+
+// Duplicate is returned when adding a User who is already stored for the Server
+var Duplicate = errors.New("user already exists")
+
+// retries is the number of times Update runs a change before giving up on conflicts
+const retries = 3
+
+// Store defines every storage operation of the game.
+// The settings and the structure of a Server (playing, building, archived, demotion, prefix, admin, fingerprint, Discord IDs
+// and replacement) are only stored if it was not changed since it was loaded, otherwise Conflict is returned and the Server
+// has to be loaded again, see Update
+type Store interface {
+	// GetServer returns a Server object for the Discord Guild
+	GetServer(g string) (*structure.Server, error)
+	// GetPlayingServers returns the IDs of every Discord Guild with a running game
+	GetPlayingServers() ([]string, error)
+	// CreateServer uploads a Server object
+	CreateServer(s *structure.Server) error
+	// UpdateServerPlaying runs, pauses or stops a game for given Server
+	UpdateServerPlaying(s *structure.Server) error
+	// UpdateServerBuilding stores whether the game of given Server is still being built
+	UpdateServerBuilding(s *structure.Server) error
+	// UpdateServerArchived stores when the game of given Server was archived, 0 if it is not
+	UpdateServerArchived(s *structure.Server) error
+	// GetArchivedServers returns the IDs of every Discord Guild with a game archived before `t`
+	GetArchivedServers(t time.Time) ([]string, error)
+	// UpdateServerDemotion enables or disables demotion for inactivity for given Server
+	UpdateServerDemotion(s *structure.Server) error
+	// UpdateServerPrefix stores the command prefix of given Server, empty for the default one
+	UpdateServerPrefix(s *structure.Server) error
+	// UpdateServerAdmin stores the override Roles and the admin log Channel of given Server
+	UpdateServerAdmin(s *structure.Server) error
+	// GetServerStructure returns the stored Server object of the Discord Guild as it is, without the game definition
+	GetServerStructure(g string) (*structure.Server, error)
+	// GetRolloutServers returns the IDs of up to `limit` Discord Guilds with a running game built from another structure than `fingerprint`, least recently tried first
+	GetRolloutServers(fingerprint string, limit int) ([]string, error)
+	// UpdateServerFingerprint stores the fingerprint of the structure the game of given Server was built from and when a rollout to it was last tried
+	UpdateServerFingerprint(s *structure.Server) error
+	// RemoveServerStructure removes the stored `keys` of every kind ("resources", "roles", "channels" or "messages") from given Server
+	RemoveServerStructure(s *structure.Server, keys map[string][]string) error
+	// UpdateServerStructure stores the Discord IDs of every Role, Channel and Message of given Server
+	UpdateServerStructure(s *structure.Server) error
+	// GetServerUser returns the User with ID `u` playing the game of given Server
+	GetServerUser(s *structure.Server, u string) (*structure.User, error)
+	// GetDepartedServerUser returns the User with ID `u` who left the Discord Guild of given Server, keeping their progress
+	GetDepartedServerUser(s *structure.Server, u string) (*structure.User, error)
+	// GetServerUsers returns every User playing the game of given Server
+	GetServerUsers(s *structure.Server) ([]*structure.User, error)
+	// CountServerUsers returns the number of Users playing the game of given Server
+	CountServerUsers(s *structure.Server) (int, error)
+	// AddServerUser adds a new User to the game, returning Duplicate if they are already stored, playing or departed
+	AddServerUser(s *structure.Server, u *structure.User) error
+	// RemoveServerUser removes an existing User from the game
+	RemoveServerUser(s *structure.Server, u *structure.User) error
+	// DepartServerUser moves User `u` who left the Discord Guild out of the game, keeping their progress
+	DepartServerUser(s *structure.Server, u *structure.User) error
+	// RejoinServerUser moves departed User `u` back into the game
+	RejoinServerUser(s *structure.Server, u *structure.User) error
+	// ForgetServerUser removes the kept progress of departed User `u`
+	ForgetServerUser(s *structure.Server, u *structure.User) error
+	// PurgeDepartedUsers removes the kept progress of every User who left their Discord Guild before `t`
+	PurgeDepartedUsers(t time.Time) error
+	// UpdateServerUserRole stores the game Role of an existing User
+	UpdateServerUserRole(s *structure.Server, u *structure.User) error
+	// ReplaceServerUserRole moves every User with game Role `old` to game Role `new`
+	ReplaceServerUserRole(s *structure.Server, old string, new string) error
+	// DemoteServerUser stores the game Role and activity of a demoted User, keeping their contribution
+	DemoteServerUser(s *structure.Server, u *structure.User) error
+	// GatherResource adds `amount` of Resource `r` to the Server and to the User's contribution
+	GatherResource(s *structure.Server, u *structure.User, r string, amount int, cooldown time.Duration) (bool, error)
+	// AddServerCard adds a posted Card to the game
+	AddServerCard(s *structure.Server, c *structure.ActiveCard) error
+	// ClaimServerCard claims Card `c` for User `u`, paying `costs` and receiving `rewards` and `contribution`
+	ClaimServerCard(s *structure.Server, c *structure.ActiveCard, u *structure.User, costs map[string]int, rewards map[string]int, contribution int) (bool, error)
+	// RemoveExpiredServerCards removes every Card that expired before `t` from the game
+	RemoveExpiredServerCards(s *structure.Server, t time.Time) error
+	// AddServerResources adds `amounts` to the Resources of given Server
+	AddServerResources(s *structure.Server, amounts map[string]int) error
+	// ResetServerProgress removes every User, Card and Resource of given Server, keeping its Discord structure and settings
+	ResetServerProgress(s *structure.Server) error
+	// ReplaceServer stores given Server in place of the stored one with the same ID, removing its Users
+	ReplaceServer(s *structure.Server) error
+	// DeleteServer removes a Server object
+	DeleteServer(s *structure.Server) error
+	// GetDueJobs returns every Job scheduled to run before `t`
+	GetDueJobs(t time.Time) ([]*structure.Job, error)
+	// ScheduleJob creates Job `j` or updates the interval of an already scheduled one
+	ScheduleJob(j *structure.Job) error
+	// ClaimJob moves Job `j` to run next at `next`
+	ClaimJob(j *structure.Job, next time.Time) (bool, error)
+	// DeleteServerJobs removes every Job of the Server with ID `s`
+	DeleteServerJobs(s string) error
+	// ClaimCooldown starts cooldown bucket `key` for `d` if it is not running, otherwise returns the time left on it
+	ClaimCooldown(key string, d time.Duration) (time.Duration, error)
+	// ReleaseCooldown stops cooldown bucket `key`
+	ReleaseCooldown(key string) error
+	// Close releases the resources held by the Store
+	Close() error
+}
+
+// Update runs `change`, which stores settings of Server `s` in `store`.
+// On a Conflict, `s` is loaded again and `change` runs on the fresh copy, so it has to work out its values from `s` every time
+func Update(store Store, s *structure.Server, change func(*structure.Server) error) error {
+	for attempt := 1; ; attempt++ {
+		err := change(s)
+		if err != Conflict || attempt == retries {
+			return err
+		}
+		fresh, err := store.GetServer(s.ID)
+		if err != nil {
+			return err
+		}
+		*s = *fresh
 	}
-	return client
 }
 
-// GetServer returns a Server object for the Discord Guild
-func GetServer(g string) (*structure.Server, error) {
-	client := connect()
-	defer client.Disconnect(context.Background())
-	collection := client.Database("knights-of-discord").Collection("servers")
-	filter := bson.NewDocument(bson.EC.String("id", g))
-	server := structure.DefaultServer
-	dbServer := structure.Server{}
-	doc := collection.FindOne(context.Background(), filter)
-	err := doc.Decode(&dbServer)
+// bucket contains the expiry of a cooldown bucket, stored as Unix time
+type bucket struct {
+	Key     string `bson:"key"`
+	Expires int64  `bson:"expires"`
+}
+
+// merge fills the default Server object with the stored game information of `dbServer`.
+// Resources, Roles, Channels and Messages missing from `dbServer` keep their defaults
+func merge(dbServer *structure.Server, err error) (*structure.Server, error) {
+	server := structure.DefaultServer.Copy()
 
 	if err == nil {
 		server.ID = dbServer.ID
+		server.Version = dbServer.Version
+		server.Revision = dbServer.Revision
+		server.Structure = dbServer.Structure
+		server.RolloutTried = dbServer.RolloutTried
 		server.Playing = dbServer.Playing
+		server.Building = dbServer.Building
+		server.Paused = dbServer.Paused
+		server.Archived = dbServer.Archived
+		server.Uninstalled = dbServer.Uninstalled
+		server.Demotion = dbServer.Demotion
 		for key, resource := range server.Resources {
-			resource.Count = dbServer.Resources[key].Count
+			if stored := dbServer.Resources[key]; stored != nil {
+				resource.Count = stored.Count
+			}
 		}
+		server.Prefix = dbServer.Prefix
+		server.Overrides = dbServer.Overrides
+		server.AdminLog = dbServer.AdminLog
 		server.EveryoneRole = dbServer.EveryoneRole
 		for key, role := range server.Roles {
-			role.ID = dbServer.Roles[key].ID
+			if stored := dbServer.Roles[key]; stored != nil {
+				role.ID = stored.ID
+			}
+		}
+		if dbServer.Category != nil {
+			server.Category.ID = dbServer.Category.ID
 		}
-		server.Category.ID = dbServer.Category.ID
 		for key, channel := range server.Channels {
-			channel.ID = dbServer.Channels[key].ID
+			if stored := dbServer.Channels[key]; stored != nil {
+				channel.ID = stored.ID
+			}
 		}
 		for key, message := range server.Messages {
-			message.ID = dbServer.Messages[key].ID
-			message.ChannelID = dbServer.Messages[key].ChannelID
+			if stored := dbServer.Messages[key]; stored != nil {
+				message.ID = stored.ID
+				message.ChannelID = stored.ChannelID
+			}
 		}
-		server.Users = dbServer.Users
+		server.ActiveCards = dbServer.ActiveCards
 	}
 
 	return &server, err
 }
-
-// CreateServer uploads a Server object
-func CreateServer(s *structure.Server) error {
-	client := connect()
-	defer client.Disconnect(context.Background())
-	collection := client.Database("knights-of-discord").Collection("servers")
-	_, err := collection.InsertOne(context.Background(), s)
-	return err
-}
-
-// UpdateServerPlaying runs or stops a game for given Server
-func UpdateServerPlaying(s *structure.Server) error {
-	client := connect()
-	defer client.Disconnect(context.Background())
-	collection := client.Database("knights-of-discord").Collection("servers")
-	filter := bson.NewDocument(bson.EC.String("id", s.ID))
-	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set", bson.EC.Boolean("playing", s.Playing)))
-	_, err := collection.UpdateOne(context.Background(), filter, replacement)
-	return err
-}
-
-// AddServerUser adds a new User to the game
-func AddServerUser(s *structure.Server, u *structure.User) error {
-	client := connect()
-	defer client.Disconnect(context.Background())
-	collection := client.Database("knights-of-discord").Collection("servers")
-	filter := bson.NewDocument(bson.EC.String("id", s.ID))
-	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$push", bson.EC.Interface("users", u)))
-	_, err := collection.UpdateOne(context.Background(), filter, replacement)
-	return err
-}
-
-// RemoveServerUser removes an existing User from the game
-func RemoveServerUser(s *structure.Server, u *structure.User) error {
-	client := connect()
-	defer client.Disconnect(context.Background())
-	collection := client.Database("knights-of-discord").Collection("servers")
-	filter := bson.NewDocument(bson.EC.String("id", s.ID))
-	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$pull", bson.EC.Interface("users", u)))
-	_, err := collection.UpdateOne(context.Background(), filter, replacement)
-	return err
-}
-
-// DeleteServer removes a Server object
-func DeleteServer(s *structure.Server) error {
-	client := connect()
-	defer client.Disconnect(context.Background())
-	collection := client.Database("knights-of-discord").Collection("servers")
-	filter := bson.NewDocument(bson.EC.String("id", s.ID))
-	_, err := collection.DeleteOne(context.Background(), filter)
-	return err
-}
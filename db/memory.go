@@ -0,0 +1,760 @@
+package db
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/mongodb/mongo-go-driver/bson"
+)
+
+// MemoryStore is a Store keeping every object in memory, behaving like MongoStore
+type MemoryStore struct {
+	mu        sync.Mutex
+	servers   map[string]*structure.Server
+	players   map[string]*structure.User
+	jobs      map[string]*structure.Job
+	cooldowns map[string]int64
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore creates a new empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		servers:   map[string]*structure.Server{},
+		players:   map[string]*structure.User{},
+		jobs:      map[string]*structure.Job{},
+		cooldowns: map[string]int64{},
+	}
+}
+
+// clone copies `in` into `out` through BSON, so only stored fields are kept like in MongoStore
+func clone(in interface{}, out interface{}) error {
+	b, err := bson.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(b, out)
+}
+
+// user returns the stored User with ID `u` of the Server with ID `s`, if they are playing
+func (m *MemoryStore) user(s string, u string) *structure.User {
+	user, ok := m.players[s+"/"+u]
+	if !ok || user.Departed != 0 {
+		return nil
+	}
+	return user
+}
+
+// GetServer returns a Server object for the Discord Guild
+func (m *MemoryStore) GetServer(g string) (*structure.Server, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.servers[g]
+	if !ok {
+		return merge(&structure.Server{}, NotFound)
+	}
+	dbServer := structure.Server{}
+	err := clone(stored, &dbServer)
+	return merge(&dbServer, err)
+}
+
+// GetPlayingServers returns the IDs of every Discord Guild with a running game
+func (m *MemoryStore) GetPlayingServers() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := []string{}
+	for id, server := range m.servers {
+		if server.Playing {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// CreateServer uploads a Server object
+func (m *MemoryStore) CreateServer(s *structure.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.Version = SchemaVersion
+	s.Revision = 0
+	server := &structure.Server{}
+	err := clone(s, server)
+	if err != nil {
+		return err
+	}
+	m.servers[s.ID] = server
+	return nil
+}
+
+// swap returns the stored Server of given Server and moves both to the next revision, if it is still at the revision it was loaded with.
+// It has to be called with the lock held
+func (m *MemoryStore) swap(s *structure.Server) (*structure.Server, error) {
+	server, ok := m.servers[s.ID]
+	if !ok || server.Revision != s.Revision {
+		return nil, Conflict
+	}
+	server.Revision++
+	s.Revision++
+	return server, nil
+}
+
+// UpdateServerPlaying runs, pauses or stops a game for given Server
+func (m *MemoryStore) UpdateServerPlaying(s *structure.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	server, err := m.swap(s)
+	if err != nil {
+		return err
+	}
+	server.Playing = s.Playing
+	server.Paused = s.Paused
+	return nil
+}
+
+// UpdateServerBuilding stores whether the game of given Server is still being built
+func (m *MemoryStore) UpdateServerBuilding(s *structure.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	server, err := m.swap(s)
+	if err != nil {
+		return err
+	}
+	server.Building = s.Building
+	server.Playing = s.Playing
+	return nil
+}
+
+// UpdateServerArchived stores when the game of given Server was archived, 0 if it is not
+func (m *MemoryStore) UpdateServerArchived(s *structure.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	server, err := m.swap(s)
+	if err != nil {
+		return err
+	}
+	server.Archived = s.Archived
+	server.Playing = s.Playing
+	return nil
+}
+
+// GetArchivedServers returns the IDs of every Discord Guild with a game archived before `t`
+func (m *MemoryStore) GetArchivedServers(t time.Time) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := []string{}
+	for id, server := range m.servers {
+		if server.Archived > 0 && server.Archived <= t.Unix() {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// UpdateServerDemotion enables or disables demotion for inactivity for given Server
+func (m *MemoryStore) UpdateServerDemotion(s *structure.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	server, err := m.swap(s)
+	if err != nil {
+		return err
+	}
+	server.Demotion = s.Demotion
+	return nil
+}
+
+// UpdateServerPrefix stores the command prefix of given Server, empty for the default one
+func (m *MemoryStore) UpdateServerPrefix(s *structure.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	server, err := m.swap(s)
+	if err != nil {
+		return err
+	}
+	server.Prefix = s.Prefix
+	return nil
+}
+
+// UpdateServerAdmin stores the override Roles and the admin log Channel of given Server
+func (m *MemoryStore) UpdateServerAdmin(s *structure.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	server, err := m.swap(s)
+	if err != nil {
+		return err
+	}
+	server.Overrides = append([]string{}, s.Overrides...)
+	server.AdminLog = s.AdminLog
+	return nil
+}
+
+// GetServerStructure returns the stored Server object of the Discord Guild as it is, without the game definition
+func (m *MemoryStore) GetServerStructure(g string) (*structure.Server, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.servers[g]
+	if !ok {
+		return nil, NotFound
+	}
+	dbServer := &structure.Server{}
+	err := clone(stored, dbServer)
+	if err != nil {
+		return nil, err
+	}
+	return dbServer, nil
+}
+
+// GetRolloutServers returns the IDs of up to `limit` Discord Guilds with a running game built from another structure than `fingerprint`, least recently tried first
+func (m *MemoryStore) GetRolloutServers(fingerprint string, limit int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := []string{}
+	for id, server := range m.servers {
+		if server.Playing && server.Structure != fingerprint {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if m.servers[ids[i]].RolloutTried != m.servers[ids[j]].RolloutTried {
+			return m.servers[ids[i]].RolloutTried < m.servers[ids[j]].RolloutTried
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// UpdateServerFingerprint stores the fingerprint of the structure the game of given Server was built from and when a rollout to it was last tried
+func (m *MemoryStore) UpdateServerFingerprint(s *structure.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	server, err := m.swap(s)
+	if err != nil {
+		return err
+	}
+	server.Structure = s.Structure
+	server.RolloutTried = s.RolloutTried
+	return nil
+}
+
+// RemoveServerStructure removes the stored `keys` of every kind ("resources", "roles", "channels" or "messages") from given Server
+func (m *MemoryStore) RemoveServerStructure(s *structure.Server, keys map[string][]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(keys["resources"])+len(keys["roles"])+len(keys["channels"])+len(keys["messages"]) == 0 {
+		return nil
+	}
+	server, err := m.swap(s)
+	if err != nil {
+		return err
+	}
+	for kind, list := range keys {
+		for _, key := range list {
+			switch kind {
+			case "resources":
+				delete(server.Resources, key)
+			case "roles":
+				delete(server.Roles, key)
+			case "channels":
+				delete(server.Channels, key)
+			case "messages":
+				delete(server.Messages, key)
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateServerStructure stores the Discord IDs of every Role, Channel and Message of given Server
+func (m *MemoryStore) UpdateServerStructure(s *structure.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := &structure.Server{}
+	err := clone(s, stored)
+	if err != nil {
+		return err
+	}
+	server, err := m.swap(s)
+	if err != nil {
+		return err
+	}
+	// Like $set, only the given keys are replaced
+	server.EveryoneRole = stored.EveryoneRole
+	server.Category = stored.Category
+	if server.Roles == nil {
+		server.Roles = map[string]*structure.Role{}
+	}
+	for key, role := range stored.Roles {
+		server.Roles[key] = role
+	}
+	if server.Channels == nil {
+		server.Channels = map[string]*structure.Channel{}
+	}
+	for key, channel := range stored.Channels {
+		server.Channels[key] = channel
+	}
+	if server.Messages == nil {
+		server.Messages = map[string]*structure.Message{}
+	}
+	for key, message := range stored.Messages {
+		server.Messages[key] = message
+	}
+	return nil
+}
+
+// GetServerUser returns the User with ID `u` playing the game of given Server
+func (m *MemoryStore) GetServerUser(s *structure.Server, u string) (*structure.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := m.user(s.ID, u)
+	if stored == nil {
+		return nil, NotFound
+	}
+	user := &structure.User{}
+	err := clone(stored, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetDepartedServerUser returns the User with ID `u` who left the Discord Guild of given Server, keeping their progress
+func (m *MemoryStore) GetDepartedServerUser(s *structure.Server, u string) (*structure.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.players[s.ID+"/"+u]
+	if !ok || stored.Departed == 0 {
+		return nil, NotFound
+	}
+	user := &structure.User{}
+	err := clone(stored, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetServerUsers returns every User playing the game of given Server
+func (m *MemoryStore) GetServerUsers(s *structure.Server) ([]*structure.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	users := []*structure.User{}
+	for _, stored := range m.players {
+		if stored.Guild == s.ID && stored.Departed == 0 {
+			user := &structure.User{}
+			err := clone(stored, user)
+			if err != nil {
+				return nil, err
+			}
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// CountServerUsers returns the number of Users playing the game of given Server
+func (m *MemoryStore) CountServerUsers(s *structure.Server) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, stored := range m.players {
+		if stored.Guild == s.ID && stored.Departed == 0 {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// AddServerUser adds a new User to the game, returning Duplicate if they are already stored, playing or departed
+func (m *MemoryStore) AddServerUser(s *structure.Server, u *structure.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u.Guild = s.ID
+	// Like the unique index, each User is stored once
+	if _, ok := m.players[s.ID+"/"+u.ID]; ok {
+		return Duplicate
+	}
+	user := &structure.User{}
+	err := clone(u, user)
+	if err != nil {
+		return err
+	}
+	m.players[s.ID+"/"+u.ID] = user
+	return nil
+}
+
+// RemoveServerUser removes an existing User from the game
+func (m *MemoryStore) RemoveServerUser(s *structure.Server, u *structure.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.players, s.ID+"/"+u.ID)
+	return nil
+}
+
+// DepartServerUser moves User `u` who left the Discord Guild out of the game, keeping their progress
+func (m *MemoryStore) DepartServerUser(s *structure.Server, u *structure.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.user(s.ID, u.ID); user != nil {
+		user.Departed = u.Departed
+	}
+	return nil
+}
+
+// RejoinServerUser moves departed User `u` back into the game
+func (m *MemoryStore) RejoinServerUser(s *structure.Server, u *structure.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.players[s.ID+"/"+u.ID]
+	if !ok || user.Departed == 0 {
+		return nil
+	}
+	user.Role = u.Role
+	user.LastActive = u.LastActive
+	user.Departed = 0
+	return nil
+}
+
+// ForgetServerUser removes the kept progress of departed User `u`
+func (m *MemoryStore) ForgetServerUser(s *structure.Server, u *structure.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user, ok := m.players[s.ID+"/"+u.ID]; ok && user.Departed != 0 {
+		delete(m.players, s.ID+"/"+u.ID)
+	}
+	return nil
+}
+
+// PurgeDepartedUsers removes the kept progress of every User who left their Discord Guild before `t`
+func (m *MemoryStore) PurgeDepartedUsers(t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, user := range m.players {
+		if user.Departed > 0 && user.Departed <= t.Unix() {
+			delete(m.players, key)
+		}
+	}
+	return nil
+}
+
+// UpdateServerUserRole stores the game Role of an existing User
+func (m *MemoryStore) UpdateServerUserRole(s *structure.Server, u *structure.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user, ok := m.players[s.ID+"/"+u.ID]; ok {
+		user.Role = u.Role
+	}
+	return nil
+}
+
+// ReplaceServerUserRole moves every User with game Role `old` to game Role `new`
+func (m *MemoryStore) ReplaceServerUserRole(s *structure.Server, old string, new string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, user := range m.players {
+		if user.Guild == s.ID && user.Role == old {
+			user.Role = new
+		}
+	}
+	return nil
+}
+
+// DemoteServerUser stores the game Role and activity of a demoted User, keeping their contribution
+func (m *MemoryStore) DemoteServerUser(s *structure.Server, u *structure.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user, ok := m.players[s.ID+"/"+u.ID]; ok {
+		user.Role = u.Role
+		user.LastActive = u.LastActive
+	}
+	return nil
+}
+
+// GatherResource adds `amount` of Resource `r` to the Server and to the User's contribution.
+// The update only happens if the User has not gathered `r` within `cooldown`, reported by the returned bool
+func (m *MemoryStore) GatherResource(s *structure.Server, u *structure.User, r string, amount int, cooldown time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	server, ok := m.servers[s.ID]
+	if !ok {
+		return false, nil
+	}
+	user := m.user(s.ID, u.ID)
+	if user == nil {
+		return false, nil
+	}
+	now := time.Now()
+	if last, ok := user.Gathered[r]; ok && last > now.Add(-cooldown).Unix() {
+		return false, nil
+	}
+
+	user.Contribution += amount
+	if user.Gathered == nil {
+		user.Gathered = map[string]int64{}
+	}
+	user.Gathered[r] = now.Unix()
+	user.LastActive = now.Unix()
+	m.addResources(server, map[string]int{r: amount})
+	return true, nil
+}
+
+// AddServerCard adds a posted Card to the game
+func (m *MemoryStore) AddServerCard(s *structure.Server, c *structure.ActiveCard) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if server, ok := m.servers[s.ID]; ok {
+		card := &structure.ActiveCard{}
+		err := clone(c, card)
+		if err != nil {
+			return err
+		}
+		server.ActiveCards = append(server.ActiveCards, card)
+	}
+	return nil
+}
+
+// ClaimServerCard claims Card `c` for User `u`, paying `costs` and receiving `rewards` and `contribution`.
+// The update only happens if the Card has not expired, has claims left, was not claimed by the User before
+// and the Server can afford the costs, reported by the returned bool. A Card allowing no claims is never claimed
+func (m *MemoryStore) ClaimServerCard(s *structure.Server, c *structure.ActiveCard, u *structure.User, costs map[string]int, rewards map[string]int, contribution int) (bool, error) {
+	if c.Claims <= 0 {
+		return false, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	server, ok := m.servers[s.ID]
+	if !ok {
+		return false, nil
+	}
+
+	// Check Card
+	var card *structure.ActiveCard
+	for _, active := range server.ActiveCards {
+		if active.MessageID == c.MessageID {
+			card = active
+			break
+		}
+	}
+	now := time.Now()
+	if card == nil || card.Expires <= now.Unix() || len(card.ClaimedBy) >= c.Claims {
+		return false, nil
+	}
+	for _, id := range card.ClaimedBy {
+		if id == u.ID {
+			return false, nil
+		}
+	}
+
+	// Check costs
+	changes := map[string]int{}
+	for key, amount := range costs {
+		resource, ok := server.Resources[key]
+		if !ok || resource.Count < amount {
+			return false, nil
+		}
+		changes[key] -= amount
+	}
+	for key, amount := range rewards {
+		changes[key] += amount
+	}
+
+	// Check User, who may have left meanwhile
+	user, ok := m.players[s.ID+"/"+u.ID]
+	if !ok || user.Departed != 0 {
+		return false, nil
+	}
+
+	card.ClaimedBy = append(card.ClaimedBy, u.ID)
+	m.addResources(server, changes)
+	user.Contribution += contribution
+	user.LastActive = now.Unix()
+	return true, nil
+}
+
+// RemoveExpiredServerCards removes every Card that expired before `t` from the game
+func (m *MemoryStore) RemoveExpiredServerCards(s *structure.Server, t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	server, ok := m.servers[s.ID]
+	if !ok {
+		return nil
+	}
+	cards := []*structure.ActiveCard{}
+	for _, card := range server.ActiveCards {
+		if card.Expires > t.Unix() {
+			cards = append(cards, card)
+		}
+	}
+	server.ActiveCards = cards
+	return nil
+}
+
+// AddServerResources adds `amounts` to the Resources of given Server
+func (m *MemoryStore) AddServerResources(s *structure.Server, amounts map[string]int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if server, ok := m.servers[s.ID]; ok {
+		m.addResources(server, amounts)
+	}
+	return nil
+}
+
+func (m *MemoryStore) addResources(server *structure.Server, amounts map[string]int) {
+	if server.Resources == nil {
+		server.Resources = map[string]*structure.Resource{}
+	}
+	for key, amount := range amounts {
+		// Like $inc, missing Resources are created
+		if _, ok := server.Resources[key]; !ok {
+			server.Resources[key] = &structure.Resource{}
+		}
+		server.Resources[key].Count += amount
+	}
+}
+
+// ResetServerProgress removes every User, Card and Resource of given Server, keeping its Discord structure and settings
+func (m *MemoryStore) ResetServerProgress(s *structure.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	server, ok := m.servers[s.ID]
+	if !ok {
+		return nil
+	}
+	server.ActiveCards = []*structure.ActiveCard{}
+	for _, resource := range server.Resources {
+		resource.Count = 0
+	}
+	m.removePlayers(s.ID)
+	return nil
+}
+
+// ReplaceServer stores given Server in place of the stored one with the same ID, removing its Users
+func (m *MemoryStore) ReplaceServer(s *structure.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.swap(s); err != nil {
+		return err
+	}
+	s.Version = SchemaVersion
+	server := &structure.Server{}
+	err := clone(s, server)
+	if err != nil {
+		return err
+	}
+	m.servers[s.ID] = server
+	m.removePlayers(s.ID)
+	return nil
+}
+
+// DeleteServer removes a Server object
+func (m *MemoryStore) DeleteServer(s *structure.Server) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.servers, s.ID)
+	m.removePlayers(s.ID)
+	return nil
+}
+
+func (m *MemoryStore) removePlayers(s string) {
+	for key, user := range m.players {
+		if user.Guild == s {
+			delete(m.players, key)
+		}
+	}
+}
+
+// GetDueJobs returns every Job scheduled to run before `t`
+func (m *MemoryStore) GetDueJobs(t time.Time) ([]*structure.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := []*structure.Job{}
+	for _, stored := range m.jobs {
+		if stored.Next <= t.Unix() {
+			job := &structure.Job{}
+			err := clone(stored, job)
+			if err != nil {
+				return nil, err
+			}
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Next < jobs[j].Next
+	})
+	return jobs, nil
+}
+
+// ScheduleJob creates Job `j` or updates the interval of an already scheduled one
+func (m *MemoryStore) ScheduleJob(j *structure.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, ok := m.jobs[j.Server+"/"+j.Name]; ok {
+		stored.Interval = j.Interval
+		return nil
+	}
+	job := &structure.Job{}
+	err := clone(j, job)
+	if err != nil {
+		return err
+	}
+	m.jobs[j.Server+"/"+j.Name] = job
+	return nil
+}
+
+// ClaimJob moves Job `j` to run next at `next`.
+// The update only happens if no one else claimed the Job since it was loaded, reported by the returned bool
+func (m *MemoryStore) ClaimJob(j *structure.Job, next time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.jobs[j.Server+"/"+j.Name]
+	if !ok || stored.Next != j.Next {
+		return false, nil
+	}
+	stored.Next = next.Unix()
+	return true, nil
+}
+
+// DeleteServerJobs removes every Job of the Server with ID `s`
+func (m *MemoryStore) DeleteServerJobs(s string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, job := range m.jobs {
+		if job.Server == s {
+			delete(m.jobs, key)
+		}
+	}
+	return nil
+}
+
+// ClaimCooldown starts cooldown bucket `key` for `d` if it is not running, otherwise returns the time left on it
+func (m *MemoryStore) ClaimCooldown(key string, d time.Duration) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if expires, ok := m.cooldowns[key]; ok && expires > now.Unix() {
+		left := time.Unix(expires, 0).Sub(now)
+		if left < time.Second {
+			left = time.Second
+		}
+		return left, nil
+	}
+	m.cooldowns[key] = now.Add(d).Unix()
+	return 0, nil
+}
+
+// ReleaseCooldown stops cooldown bucket `key`
+func (m *MemoryStore) ReleaseCooldown(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.cooldowns, key)
+	return nil
+}
+
+// Close releases the resources held by the Store
+func (m *MemoryStore) Close() error {
+	return nil
+}
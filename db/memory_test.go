@@ -0,0 +1,563 @@
+package db
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+)
+
+func TestMain(m *testing.M) {
+	// Stored Servers are merged into the game definition
+	file, err := ioutil.ReadFile("../structure.json")
+	if err != nil {
+		panic(err)
+	}
+	err = json.Unmarshal(file, &structure.DefaultServer)
+	if err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newServer stores a new Server for Discord Guild `g` in `store`
+func newServer(t *testing.T, store Store, g string) *structure.Server {
+	server := structure.DefaultServer.Copy()
+	server.ID = g
+	server.Playing = true
+	err := store.CreateServer(&server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &server
+}
+
+func TestGetServerNotFound(t *testing.T) {
+	store := NewMemoryStore()
+	_, err := store.GetServer("missing")
+	if err != NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+	_, err = store.GetServerStructure("missing")
+	if err != NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+func TestCreateServer(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	server.Roles["r1"].ID = "role"
+	err := store.UpdateServerStructure(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := store.GetServer("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Playing || stored.Version != SchemaVersion || stored.Revision != server.Revision {
+		t.Fatalf("unexpected stored Server %+v", stored)
+	}
+	if stored.Roles["r1"].ID != "role" || stored.Roles["r1"].DefaultName == "" {
+		t.Fatalf("expected stored IDs merged into the game definition, got %+v", stored.Roles["r1"])
+	}
+}
+
+func TestUpdateServerConflict(t *testing.T) {
+	store := NewMemoryStore()
+	first := newServer(t, store, "g")
+	second, err := store.GetServer("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first.Prefix = "!"
+	err = store.UpdateServerPrefix(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Demotion = true
+	err = store.UpdateServerDemotion(second)
+	if err != Conflict {
+		t.Fatalf("expected Conflict, got %v", err)
+	}
+
+	// Update runs the change again on the stored Server
+	err = Update(store, second, func(server *structure.Server) error {
+		server.Demotion = true
+		return store.UpdateServerDemotion(server)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := store.GetServer("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Prefix != "!" || !stored.Demotion {
+		t.Fatalf("expected both updates stored, got prefix %q and demotion %v", stored.Prefix, stored.Demotion)
+	}
+}
+
+func TestAddServerUserDuplicate(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	err := store.AddServerUser(server, &structure.User{ID: "u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.AddServerUser(server, &structure.User{ID: "u"})
+	if err != Duplicate {
+		t.Fatalf("expected Duplicate, got %v", err)
+	}
+
+	// Departed Users keep their place
+	user, err := store.GetServerUser(server, "u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Departed = time.Now().Unix()
+	err = store.DepartServerUser(server, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.GetServerUser(server, "u")
+	if err != NotFound {
+		t.Fatalf("expected NotFound for a departed User, got %v", err)
+	}
+	err = store.AddServerUser(server, &structure.User{ID: "u"})
+	if err != Duplicate {
+		t.Fatalf("expected Duplicate for a departed User, got %v", err)
+	}
+	_, err = store.GetDepartedServerUser(server, "u")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGatherResource(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	user := &structure.User{ID: "u"}
+	err := store.AddServerUser(server, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gathered, err := store.GatherResource(server, user, "r1", 3, time.Hour)
+	if err != nil || !gathered {
+		t.Fatalf("expected first gather to succeed, got %v, %v", gathered, err)
+	}
+	gathered, err = store.GatherResource(server, user, "r1", 3, time.Hour)
+	if err != nil || gathered {
+		t.Fatalf("expected gather during cooldown to fail, got %v, %v", gathered, err)
+	}
+
+	stored, err := store.GetServer("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Resources["r1"].Count != 3 {
+		t.Fatalf("expected 3 r1, got %d", stored.Resources["r1"].Count)
+	}
+	user, err = store.GetServerUser(server, "u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Contribution != 3 || user.LastActive == 0 {
+		t.Fatalf("unexpected User %+v", user)
+	}
+}
+
+func TestClaimServerCard(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	for _, id := range []string{"u1", "u2"} {
+		err := store.AddServerUser(server, &structure.User{ID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := store.AddServerResources(server, map[string]int{"r1": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	card := &structure.ActiveCard{MessageID: "m", Expires: time.Now().Add(time.Hour).Unix(), Claims: 2, ClaimedBy: []string{}}
+	err = store.AddServerCard(server, card)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	costs := map[string]int{"r1": 4}
+	rewards := map[string]int{"r2": 1}
+	claimed, err := store.ClaimServerCard(server, card, &structure.User{ID: "u1"}, costs, rewards, 2)
+	if err != nil || !claimed {
+		t.Fatalf("expected claim to succeed, got %v, %v", claimed, err)
+	}
+	claimed, err = store.ClaimServerCard(server, card, &structure.User{ID: "u1"}, map[string]int{}, rewards, 2)
+	if err != nil || claimed {
+		t.Fatalf("expected second claim by the same User to fail, got %v, %v", claimed, err)
+	}
+	claimed, err = store.ClaimServerCard(server, card, &structure.User{ID: "u2"}, costs, rewards, 2)
+	if err != nil || claimed {
+		t.Fatalf("expected unaffordable claim to fail, got %v, %v", claimed, err)
+	}
+
+	stored, err := store.GetServer("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Resources["r1"].Count != 1 || stored.Resources["r2"].Count != 1 {
+		t.Fatalf("expected 1 r1 and 1 r2, got %d and %d", stored.Resources["r1"].Count, stored.Resources["r2"].Count)
+	}
+	user, err := store.GetServerUser(server, "u2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Contribution != 0 {
+		t.Fatalf("expected no contribution for a failed claim, got %d", user.Contribution)
+	}
+}
+
+func TestResetServerProgress(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	err := store.AddServerUser(server, &structure.User{ID: "u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.AddServerResources(server, map[string]int{"r1": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.ResetServerProgress(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := store.CountServerUsers(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := store.GetServer("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 || stored.Resources["r1"].Count != 0 {
+		t.Fatalf("expected no Users and Resources, got %d Users and %d r1", count, stored.Resources["r1"].Count)
+	}
+}
+
+func TestClaimJob(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	err := store.ScheduleJob(&structure.Job{Server: "g", Name: "production", Interval: 60, Next: now.Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := store.GetDueJobs(now)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("expected 1 due Job, got %d, %v", len(jobs), err)
+	}
+
+	claimed, err := store.ClaimJob(jobs[0], now.Add(time.Hour))
+	if err != nil || !claimed {
+		t.Fatalf("expected claim to succeed, got %v, %v", claimed, err)
+	}
+	claimed, err = store.ClaimJob(jobs[0], now.Add(time.Hour))
+	if err != nil || claimed {
+		t.Fatalf("expected second claim of the same run to fail, got %v, %v", claimed, err)
+	}
+	jobs, err = store.GetDueJobs(now)
+	if err != nil || len(jobs) != 0 {
+		t.Fatalf("expected no due Job, got %d, %v", len(jobs), err)
+	}
+}
+
+func TestClaimCooldown(t *testing.T) {
+	store := NewMemoryStore()
+	left, err := store.ClaimCooldown("key", time.Minute)
+	if err != nil || left != 0 {
+		t.Fatalf("expected free bucket, got %v, %v", left, err)
+	}
+	left, err = store.ClaimCooldown("key", time.Minute)
+	if err != nil || left <= 0 {
+		t.Fatalf("expected running bucket, got %v, %v", left, err)
+	}
+}
+
+func TestReleaseCooldown(t *testing.T) {
+	store := NewMemoryStore()
+	_, err := store.ClaimCooldown("key", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.ReleaseCooldown("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, err := store.ClaimCooldown("key", time.Minute)
+	if err != nil || left != 0 {
+		t.Fatalf("expected released bucket to be free, got %v, %v", left, err)
+	}
+}
+
+func TestUpdateServerPlayingPaused(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	server.Playing = false
+	server.Paused = true
+	err := store.UpdateServerPlaying(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := store.GetServer("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Playing || !stored.Paused || !stored.Maintained() {
+		t.Fatalf("expected a paused and maintained Server, got playing %v and paused %v", stored.Playing, stored.Paused)
+	}
+}
+
+func TestReplaceServer(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	err := store.AddServerUser(server, &structure.User{ID: "u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := *server
+
+	replacement := structure.DefaultServer.Copy()
+	replacement.ID = "g"
+	replacement.Revision = server.Revision
+	replacement.Uninstalled = true
+	err = store.ReplaceServer(&replacement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.ReplaceServer(&stale)
+	if err != Conflict {
+		t.Fatalf("expected Conflict, got %v", err)
+	}
+
+	stored, err := store.GetServer("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Playing || !stored.Uninstalled {
+		t.Fatalf("expected an uninstalled Server, got playing %v and uninstalled %v", stored.Playing, stored.Uninstalled)
+	}
+	count, err := store.CountServerUsers(stored)
+	if err != nil || count != 0 {
+		t.Fatalf("expected no Users, got %d, %v", count, err)
+	}
+}
+
+func TestGetRolloutServers(t *testing.T) {
+	store := NewMemoryStore()
+	servers := map[string]*structure.Server{}
+	for _, id := range []string{"g1", "g2", "g3"} {
+		servers[id] = newServer(t, store, id)
+		servers[id].Structure = "old"
+		err := store.UpdateServerFingerprint(servers[id])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An incomplete rollout goes behind the other Servers, a complete one leaves the batch
+	servers["g1"].RolloutTried = time.Now().Unix()
+	err := store.UpdateServerFingerprint(servers["g1"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers["g2"].Structure = "new"
+	servers["g2"].RolloutTried = time.Now().Unix()
+	err = store.UpdateServerFingerprint(servers["g2"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := store.GetRolloutServers("new", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != "g3" || ids[1] != "g1" {
+		t.Fatalf("expected g3 then g1, got %v", ids)
+	}
+}
+
+func TestUpdateServerStructureConflict(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	stale, err := store.GetServer("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.Prefix = "!"
+	err = store.UpdateServerPrefix(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.Roles["r1"].ID = "role"
+	err = store.UpdateServerStructure(stale)
+	if err != Conflict {
+		t.Fatalf("expected Conflict, got %v", err)
+	}
+	err = store.RemoveServerStructure(stale, map[string][]string{"roles": {"r1"}})
+	if err != Conflict {
+		t.Fatalf("expected Conflict, got %v", err)
+	}
+	err = store.UpdateServerFingerprint(stale)
+	if err != Conflict {
+		t.Fatalf("expected Conflict, got %v", err)
+	}
+}
+
+func TestClaimServerCardWithoutClaims(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	err := store.AddServerUser(server, &structure.User{ID: "u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	card := &structure.ActiveCard{MessageID: "m", Expires: time.Now().Add(time.Hour).Unix(), Claims: 0, ClaimedBy: []string{}}
+	err = store.AddServerCard(server, card)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := store.ClaimServerCard(server, card, &structure.User{ID: "u"}, map[string]int{}, map[string]int{"r1": 1}, 1)
+	if err != nil || claimed {
+		t.Fatalf("expected a Card allowing no claims to be refused, got %v, %v", claimed, err)
+	}
+}
+
+func TestClaimServerCardDeparted(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	user := &structure.User{ID: "u"}
+	err := store.AddServerUser(server, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	card := &structure.ActiveCard{MessageID: "m", Expires: time.Now().Add(time.Hour).Unix(), Claims: 1, ClaimedBy: []string{}}
+	err = store.AddServerCard(server, card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Departed = time.Now().Unix()
+	err = store.DepartServerUser(server, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Card is not claimed and the Server is not rewarded
+	claimed, err := store.ClaimServerCard(server, card, user, map[string]int{}, map[string]int{"r1": 1}, 1)
+	if err != nil || claimed {
+		t.Fatalf("expected claim by a departed User to fail, got %v, %v", claimed, err)
+	}
+	stored, err := store.GetServer("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Resources["r1"].Count != 0 || len(stored.ActiveCards[0].ClaimedBy) != 0 {
+		t.Fatalf("expected no reward and no claim, got %d r1 and claims %v", stored.Resources["r1"].Count, stored.ActiveCards[0].ClaimedBy)
+	}
+}
+
+func TestGatherResourceUnknownServer(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	user := &structure.User{ID: "u"}
+	err := store.AddServerUser(server, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Like a Server document removed while its User documents are left
+	delete(store.servers, "g")
+
+	gathered, err := store.GatherResource(server, user, "r1", 3, time.Hour)
+	if err != nil || gathered {
+		t.Fatalf("expected gather for an unknown Server to fail, got %v, %v", gathered, err)
+	}
+	user, err = store.GetServerUser(server, "u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Contribution != 0 {
+		t.Fatalf("expected no contribution, got %d", user.Contribution)
+	}
+}
+
+func TestDemoteServerUserKeepsContribution(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	user := &structure.User{ID: "u", Role: "new", Contribution: 50}
+	err := store.AddServerUser(server, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user.Role = "old"
+	user.Contribution = 0
+	user.LastActive = time.Now().Unix()
+	err = store.DemoteServerUser(server, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := store.GetServerUser(server, "u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Role != "old" || stored.Contribution != 50 {
+		t.Fatalf("expected role old and contribution 50, got %q and %d", stored.Role, stored.Contribution)
+	}
+}
+
+func TestPurgeDepartedUsers(t *testing.T) {
+	store := NewMemoryStore()
+	server := newServer(t, store, "g")
+	now := time.Now()
+	users := map[string]int64{"playing": 0, "old": now.Add(-2 * time.Hour).Unix(), "recent": now.Unix()}
+	for id, departed := range users {
+		user := &structure.User{ID: id}
+		err := store.AddServerUser(server, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if departed != 0 {
+			user.Departed = departed
+			err = store.DepartServerUser(server, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	err := store.PurgeDepartedUsers(now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the User who left before the cutoff is forgotten
+	_, err = store.GetDepartedServerUser(server, "old")
+	if err != NotFound {
+		t.Fatalf("expected the old departed User to be purged, got %v", err)
+	}
+	_, err = store.GetDepartedServerUser(server, "recent")
+	if err != nil {
+		t.Fatalf("expected the recently departed User to be kept, got %v", err)
+	}
+	_, err = store.GetServerUser(server, "playing")
+	if err != nil {
+		t.Fatalf("expected the playing User to be kept, got %v", err)
+	}
+}
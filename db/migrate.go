@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/mongodb/mongo-go-driver/mongo/findopt"
+)
+
+// migration upgrades the stored Server document of a Discord Guild to schema version Version.
+// Run has to be idempotent, as an interrupted migration runs again before the version is stored
+type migration struct {
+	Version     int
+	Description string
+	Run         func(ctx context.Context, database *mongo.Database, g string) error
+}
+
+// migrations lists every schema change of the Server documents, ordered by version
+var migrations = []migration{
+	{Version: 1, Description: "move embedded players into the players collection", Run: movePlayers},
+	{Version: 2, Description: "add a revision to server documents", Run: addRevision},
+}
+
+// SchemaVersion is the version of the Server documents written by this build
+var SchemaVersion = migrations[len(migrations)-1].Version
+
+// Migrated describes a migration applied, or pending in a dry run, to the Server of a Discord Guild
+type Migrated struct {
+	Guild       string
+	Version     int
+	Description string
+}
+
+// pending returns the migrations a Server document at schema version `version` still needs, in order
+func pending(version int) []migration {
+	result := []migration{}
+	for _, m := range migrations {
+		if m.Version > version {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// migrateServer applies every pending migration to the Server document of Discord Guild `g` at schema version `version`
+func (m *MongoStore) migrateServer(ctx context.Context, g string, version int) error {
+	collection := m.database.Collection("servers")
+	for _, mig := range pending(version) {
+		err := mig.Run(ctx, m.database, g)
+		if err != nil {
+			return err
+		}
+
+		// Store the version after each migration, so a failed one resumes from it
+		filter := bson.NewDocument(bson.EC.String("id", g))
+		update := bson.NewDocument(bson.EC.SubDocumentFromElements("$set", bson.EC.Int64("version", int64(mig.Version))))
+		_, err = collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Migrate applies every pending migration to every stored Server document, or only lists them if `dryRun` is set
+func (m *MongoStore) Migrate(dryRun bool) ([]*Migrated, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(bson.EC.ArrayFromElements("$or",
+		bson.VC.DocumentFromElements(bson.EC.SubDocumentFromElements("version", bson.EC.Boolean("$exists", false))),
+		bson.VC.DocumentFromElements(bson.EC.SubDocumentFromElements("version", bson.EC.Int64("$lt", int64(SchemaVersion)))),
+	))
+	cursor, err := collection.Find(ctx, filter, findopt.Projection(bson.NewDocument(
+		bson.EC.Int32("id", 1),
+		bson.EC.Int32("version", 1),
+	)))
+	if err != nil {
+		return nil, err
+	}
+	servers := []structure.Server{}
+	for cursor.Next(ctx) {
+		server := structure.Server{}
+		err = cursor.Decode(&server)
+		if err != nil {
+			cursor.Close(ctx)
+			return nil, err
+		}
+		servers = append(servers, server)
+	}
+	err = cursor.Err()
+	cursor.Close(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	migrated := []*Migrated{}
+	for _, server := range servers {
+		if !dryRun {
+			serverCtx, serverCancel := m.context()
+			err = m.migrateServer(serverCtx, server.ID, server.Version)
+			serverCancel()
+			if err != nil {
+				return migrated, err
+			}
+		}
+		for _, mig := range pending(server.Version) {
+			migrated = append(migrated, &Migrated{Guild: server.ID, Version: mig.Version, Description: mig.Description})
+		}
+	}
+	return migrated, nil
+}
+
+// embeddedPlayers contains the Users stored inside a Server document before they had their own collection
+type embeddedPlayers struct {
+	ID       string            `bson:"id"`
+	Users    []*structure.User `bson:"users"`
+	Departed []*structure.User `bson:"departed"`
+}
+
+// movePlayers moves the Users embedded in the Server document of Discord Guild `g` into the players collection
+func movePlayers(ctx context.Context, database *mongo.Database, g string) error {
+	servers := database.Collection("servers")
+	players := database.Collection("players")
+	filter := bson.NewDocument(bson.EC.String("id", g))
+	embedded := embeddedPlayers{}
+	err := servers.FindOne(ctx, filter).Decode(&embedded)
+	if err != nil {
+		return err
+	}
+
+	// Move Users, skipping the ones moved by an interrupted migration
+	for _, user := range append(embedded.Users, embedded.Departed...) {
+		user.Guild = embedded.ID
+		_, err = players.InsertOne(ctx, user)
+		if err != nil && !isDuplicateKey(err) {
+			return err
+		}
+	}
+
+	// Remove embedded Users
+	update := bson.NewDocument(bson.EC.SubDocumentFromElements("$unset",
+		bson.EC.String("users", ""),
+		bson.EC.String("departed", ""),
+	))
+	_, err = servers.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// addRevision starts the revision of the Server document of Discord Guild `g`, used to detect concurrent changes
+func addRevision(ctx context.Context, database *mongo.Database, g string) error {
+	servers := database.Collection("servers")
+	filter := bson.NewDocument(
+		bson.EC.String("id", g),
+		bson.EC.SubDocumentFromElements("revision", bson.EC.Boolean("$exists", false)),
+	)
+	update := bson.NewDocument(bson.EC.SubDocumentFromElements("$set", bson.EC.Int64("revision", 0)))
+	_, err := servers.UpdateOne(ctx, filter, update)
+	return err
+}
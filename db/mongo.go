@@ -0,0 +1,878 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/config"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/mongodb/mongo-go-driver/bson"
+	"github.com/mongodb/mongo-go-driver/mongo"
+	"github.com/mongodb/mongo-go-driver/mongo/clientopt"
+	"github.com/mongodb/mongo-go-driver/mongo/findopt"
+	"github.com/mongodb/mongo-go-driver/mongo/updateopt"
+)
+
+// MongoStore is a Store backed by a MongoDB database, sharing one pooled client between all operations
+type MongoStore struct {
+	client   *mongo.Client
+	database *mongo.Database
+	timeout  time.Duration
+}
+
+var _ Store = &MongoStore{}
+
+// NewMongoStore connects to the MongoDB database from the config
+func NewMongoStore() (*MongoStore, error) {
+	c := config.Get()
+	client, err := mongo.NewClientWithOptions("mongodb://"+c.DBUser+":"+c.DBPassword+"@"+c.DBUrl,
+		clientopt.MaxConnsPerHost(uint16(c.DBPoolSize)),
+		clientopt.MaxIdleConnsPerHost(uint16(c.DBPoolSize)),
+		clientopt.ConnectTimeout(time.Duration(c.DBConnectTimeout)*time.Second),
+		clientopt.ServerSelectionTimeout(time.Duration(c.DBConnectTimeout)*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.DBConnectTimeout)*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cooldown buckets are claimed through upserts, which need unique keys
+	database := client.Database("knights-of-discord")
+	_, err = database.Collection("cooldowns").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.NewDocument(bson.EC.Int32("key", 1)),
+		Options: mongo.NewIndexOptionsBuilder().Unique(true).Build(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Users are looked up by Guild and ID, and each of them is stored once
+	_, err = database.Collection("players").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.NewDocument(bson.EC.Int32("guild", 1), bson.EC.Int32("id", 1)),
+		Options: mongo.NewIndexOptionsBuilder().Unique(true).Build(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoStore{
+		client:   client,
+		database: database,
+		timeout:  time.Duration(c.DBTimeout) * time.Second,
+	}, nil
+}
+
+// Close disconnects the client from the database
+func (m *MongoStore) Close() error {
+	ctx, cancel := m.context()
+	defer cancel()
+	return m.client.Disconnect(ctx)
+}
+
+// context returns a context with the operation deadline from the config
+func (m *MongoStore) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), m.timeout)
+}
+
+// swapServer applies `update` to given Server only if it is still at the revision it was loaded with, moving it to the next one
+func (m *MongoStore) swapServer(s *structure.Server, update *bson.Document) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(bson.EC.String("id", s.ID), bson.EC.Int64("revision", s.Revision))
+	update.Append(bson.EC.SubDocumentFromElements("$inc", bson.EC.Int64("revision", 1)))
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return Conflict
+	}
+	s.Revision++
+	return nil
+}
+
+// GetServer returns a Server object for the Discord Guild
+func (m *MongoStore) GetServer(g string) (*structure.Server, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(bson.EC.String("id", g))
+	dbServer := structure.Server{}
+	doc := collection.FindOne(ctx, filter)
+	err := doc.Decode(&dbServer)
+
+	// Upgrade documents stored by older versions before using them
+	if err == nil && dbServer.Version < SchemaVersion {
+		err = m.migrateServer(ctx, g, dbServer.Version)
+		if err != nil {
+			return merge(&dbServer, err)
+		}
+		dbServer = structure.Server{}
+		err = collection.FindOne(ctx, filter).Decode(&dbServer)
+	}
+	return merge(&dbServer, err)
+}
+
+// GetPlayingServers returns the IDs of every Discord Guild with a running game
+func (m *MongoStore) GetPlayingServers() ([]string, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(bson.EC.Boolean("playing", true))
+	cursor, err := collection.Find(ctx, filter, findopt.Projection(bson.NewDocument(bson.EC.Int32("id", 1))))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ids := []string{}
+	for cursor.Next(ctx) {
+		server := structure.Server{}
+		err = cursor.Decode(&server)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, server.ID)
+	}
+	return ids, cursor.Err()
+}
+
+// CreateServer uploads a Server object
+func (m *MongoStore) CreateServer(s *structure.Server) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	s.Version = SchemaVersion
+	s.Revision = 0
+	_, err := collection.InsertOne(ctx, s)
+	return err
+}
+
+// UpdateServerPlaying runs, pauses or stops a game for given Server
+func (m *MongoStore) UpdateServerPlaying(s *structure.Server) error {
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set",
+		bson.EC.Boolean("playing", s.Playing),
+		bson.EC.Boolean("paused", s.Paused),
+	))
+	return m.swapServer(s, replacement)
+}
+
+// UpdateServerBuilding stores whether the game of given Server is still being built
+func (m *MongoStore) UpdateServerBuilding(s *structure.Server) error {
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set",
+		bson.EC.Boolean("building", s.Building),
+		bson.EC.Boolean("playing", s.Playing),
+	))
+	return m.swapServer(s, replacement)
+}
+
+// UpdateServerArchived stores when the game of given Server was archived, 0 if it is not
+func (m *MongoStore) UpdateServerArchived(s *structure.Server) error {
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set",
+		bson.EC.Int64("archived", s.Archived),
+		bson.EC.Boolean("playing", s.Playing),
+	))
+	return m.swapServer(s, replacement)
+}
+
+// GetArchivedServers returns the IDs of every Discord Guild with a game archived before `t`
+func (m *MongoStore) GetArchivedServers(t time.Time) ([]string, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(bson.EC.SubDocumentFromElements("archived",
+		bson.EC.Int64("$gt", 0),
+		bson.EC.Int64("$lte", t.Unix()),
+	))
+	cursor, err := collection.Find(ctx, filter, findopt.Projection(bson.NewDocument(bson.EC.Int32("id", 1))))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ids := []string{}
+	for cursor.Next(ctx) {
+		server := structure.Server{}
+		err = cursor.Decode(&server)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, server.ID)
+	}
+	return ids, cursor.Err()
+}
+
+// UpdateServerDemotion enables or disables demotion for inactivity for given Server
+func (m *MongoStore) UpdateServerDemotion(s *structure.Server) error {
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set", bson.EC.Boolean("demotion", s.Demotion)))
+	return m.swapServer(s, replacement)
+}
+
+// UpdateServerPrefix stores the command prefix of given Server, empty for the default one
+func (m *MongoStore) UpdateServerPrefix(s *structure.Server) error {
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set", bson.EC.String("prefix", s.Prefix)))
+	return m.swapServer(s, replacement)
+}
+
+// UpdateServerAdmin stores the override Roles and the admin log Channel of given Server
+func (m *MongoStore) UpdateServerAdmin(s *structure.Server) error {
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set",
+		bson.EC.Interface("overrides", s.Overrides),
+		bson.EC.String("adminLog", s.AdminLog),
+	))
+	return m.swapServer(s, replacement)
+}
+
+// GetServerStructure returns the stored Server object of the Discord Guild as it is, without the game definition
+func (m *MongoStore) GetServerStructure(g string) (*structure.Server, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(bson.EC.String("id", g))
+	dbServer := &structure.Server{}
+	err := collection.FindOne(ctx, filter).Decode(dbServer)
+	if err != nil {
+		return nil, err
+	}
+	return dbServer, nil
+}
+
+// GetRolloutServers returns the IDs of up to `limit` Discord Guilds with a running game built from another structure than `fingerprint`, least recently tried first
+func (m *MongoStore) GetRolloutServers(fingerprint string, limit int) ([]string, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(
+		bson.EC.Boolean("playing", true),
+		bson.EC.SubDocumentFromElements("structure", bson.EC.String("$ne", fingerprint)),
+	)
+	cursor, err := collection.Find(ctx, filter,
+		findopt.Projection(bson.NewDocument(bson.EC.Int32("id", 1))),
+		findopt.Sort(bson.NewDocument(bson.EC.Int32("rolloutTried", 1))),
+		findopt.Limit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ids := []string{}
+	for cursor.Next(ctx) {
+		server := structure.Server{}
+		err = cursor.Decode(&server)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, server.ID)
+	}
+	return ids, cursor.Err()
+}
+
+// UpdateServerFingerprint stores the fingerprint of the structure the game of given Server was built from and when a rollout to it was last tried
+func (m *MongoStore) UpdateServerFingerprint(s *structure.Server) error {
+	return m.swapServer(s, bson.NewDocument(bson.EC.SubDocumentFromElements("$set",
+		bson.EC.String("structure", s.Structure),
+		bson.EC.Int64("rolloutTried", s.RolloutTried),
+	)))
+}
+
+// RemoveServerStructure removes the stored `keys` of every kind ("resources", "roles", "channels" or "messages") from given Server
+func (m *MongoStore) RemoveServerStructure(s *structure.Server, keys map[string][]string) error {
+	unset := bson.NewDocument()
+	for kind, list := range keys {
+		for _, key := range list {
+			unset.Append(bson.EC.String(kind+"."+key, ""))
+		}
+	}
+	if unset.Len() == 0 {
+		return nil
+	}
+	return m.swapServer(s, bson.NewDocument(bson.EC.SubDocument("$unset", unset)))
+}
+
+// UpdateServerStructure stores the Discord IDs of every Role, Channel and Message of given Server
+func (m *MongoStore) UpdateServerStructure(s *structure.Server) error {
+	set := bson.NewDocument(
+		bson.EC.String("everyoneRole", s.EveryoneRole),
+		bson.EC.String("category.id", s.Category.ID),
+	)
+	for key, role := range s.Roles {
+		set.Append(bson.EC.String("roles."+key+".id", role.ID))
+	}
+	for key, channel := range s.Channels {
+		set.Append(bson.EC.String("channels."+key+".id", channel.ID))
+	}
+	for key, message := range s.Messages {
+		set.Append(
+			bson.EC.String("messages."+key+".id", message.ID),
+			bson.EC.String("messages."+key+".channelID", message.ChannelID),
+		)
+	}
+	return m.swapServer(s, bson.NewDocument(bson.EC.SubDocument("$set", set)))
+}
+
+// GetServerUser returns the User with ID `u` playing the game of given Server
+func (m *MongoStore) GetServerUser(s *structure.Server, u string) (*structure.User, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	filter := bson.NewDocument(bson.EC.String("guild", s.ID), bson.EC.String("id", u), bson.EC.Int64("departed", 0))
+	user := &structure.User{}
+	err := collection.FindOne(ctx, filter).Decode(user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetDepartedServerUser returns the User with ID `u` who left the Discord Guild of given Server, keeping their progress
+func (m *MongoStore) GetDepartedServerUser(s *structure.Server, u string) (*structure.User, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	filter := bson.NewDocument(
+		bson.EC.String("guild", s.ID),
+		bson.EC.String("id", u),
+		bson.EC.SubDocumentFromElements("departed", bson.EC.Int64("$gt", 0)),
+	)
+	user := &structure.User{}
+	err := collection.FindOne(ctx, filter).Decode(user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetServerUsers returns every User playing the game of given Server
+func (m *MongoStore) GetServerUsers(s *structure.Server) ([]*structure.User, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	filter := bson.NewDocument(bson.EC.String("guild", s.ID), bson.EC.Int64("departed", 0))
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []*structure.User{}
+	for cursor.Next(ctx) {
+		user := &structure.User{}
+		err = cursor.Decode(user)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, cursor.Err()
+}
+
+// CountServerUsers returns the number of Users playing the game of given Server
+func (m *MongoStore) CountServerUsers(s *structure.Server) (int, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	filter := bson.NewDocument(bson.EC.String("guild", s.ID), bson.EC.Int64("departed", 0))
+	count, err := collection.CountDocuments(ctx, filter)
+	return int(count), err
+}
+
+// AddServerUser adds a new User to the game, returning Duplicate if they are already stored, playing or departed
+func (m *MongoStore) AddServerUser(s *structure.Server, u *structure.User) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	u.Guild = s.ID
+	_, err := collection.InsertOne(ctx, u)
+	if isDuplicateKey(err) {
+		return Duplicate
+	}
+	return err
+}
+
+// RemoveServerUser removes an existing User from the game
+func (m *MongoStore) RemoveServerUser(s *structure.Server, u *structure.User) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	filter := bson.NewDocument(bson.EC.String("guild", s.ID), bson.EC.String("id", u.ID))
+	_, err := collection.DeleteOne(ctx, filter)
+	return err
+}
+
+// DepartServerUser moves User `u` who left the Discord Guild out of the game, keeping their progress
+func (m *MongoStore) DepartServerUser(s *structure.Server, u *structure.User) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	filter := bson.NewDocument(bson.EC.String("guild", s.ID), bson.EC.String("id", u.ID), bson.EC.Int64("departed", 0))
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set", bson.EC.Int64("departed", u.Departed)))
+	_, err := collection.UpdateOne(ctx, filter, replacement)
+	return err
+}
+
+// RejoinServerUser moves departed User `u` back into the game
+func (m *MongoStore) RejoinServerUser(s *structure.Server, u *structure.User) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	filter := bson.NewDocument(
+		bson.EC.String("guild", s.ID),
+		bson.EC.String("id", u.ID),
+		bson.EC.SubDocumentFromElements("departed", bson.EC.Int64("$gt", 0)),
+	)
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set",
+		bson.EC.String("role", u.Role),
+		bson.EC.Int64("lastActive", u.LastActive),
+		bson.EC.Int64("departed", 0),
+	))
+	_, err := collection.UpdateOne(ctx, filter, replacement)
+	return err
+}
+
+// ForgetServerUser removes the kept progress of departed User `u`
+func (m *MongoStore) ForgetServerUser(s *structure.Server, u *structure.User) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	filter := bson.NewDocument(
+		bson.EC.String("guild", s.ID),
+		bson.EC.String("id", u.ID),
+		bson.EC.SubDocumentFromElements("departed", bson.EC.Int64("$gt", 0)),
+	)
+	_, err := collection.DeleteOne(ctx, filter)
+	return err
+}
+
+// PurgeDepartedUsers removes the kept progress of every User who left their Discord Guild before `t`
+func (m *MongoStore) PurgeDepartedUsers(t time.Time) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	filter := bson.NewDocument(bson.EC.SubDocumentFromElements("departed",
+		bson.EC.Int64("$gt", 0),
+		bson.EC.Int64("$lte", t.Unix()),
+	))
+	_, err := collection.DeleteMany(ctx, filter)
+	return err
+}
+
+// UpdateServerUserRole stores the game Role of an existing User
+func (m *MongoStore) UpdateServerUserRole(s *structure.Server, u *structure.User) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	filter := bson.NewDocument(bson.EC.String("guild", s.ID), bson.EC.String("id", u.ID))
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set", bson.EC.String("role", u.Role)))
+	_, err := collection.UpdateOne(ctx, filter, replacement)
+	return err
+}
+
+// ReplaceServerUserRole moves every User with game Role `old` to game Role `new`
+func (m *MongoStore) ReplaceServerUserRole(s *structure.Server, old string, new string) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	filter := bson.NewDocument(bson.EC.String("guild", s.ID), bson.EC.String("role", old))
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set", bson.EC.String("role", new)))
+	_, err := collection.UpdateMany(ctx, filter, replacement)
+	return err
+}
+
+// DemoteServerUser stores the game Role and activity of a demoted User, keeping their contribution
+func (m *MongoStore) DemoteServerUser(s *structure.Server, u *structure.User) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("players")
+	filter := bson.NewDocument(bson.EC.String("guild", s.ID), bson.EC.String("id", u.ID))
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set",
+		bson.EC.String("role", u.Role),
+		bson.EC.Int64("lastActive", u.LastActive),
+	))
+	_, err := collection.UpdateOne(ctx, filter, replacement)
+	return err
+}
+
+// GatherResource adds `amount` of Resource `r` to the Server and to the User's contribution.
+// The update only happens if the User has not gathered `r` within `cooldown`, reported by the returned bool
+func (m *MongoStore) GatherResource(s *structure.Server, u *structure.User, r string, amount int, cooldown time.Duration) (bool, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	now := time.Now()
+
+	// Claim the gathering on the User first, so the cooldown can not be passed twice
+	players := m.database.Collection("players")
+	filter := bson.NewDocument(
+		bson.EC.String("guild", s.ID),
+		bson.EC.String("id", u.ID),
+		bson.EC.Int64("departed", 0),
+		bson.EC.ArrayFromElements("$or",
+			bson.VC.DocumentFromElements(bson.EC.SubDocumentFromElements("gathered."+r, bson.EC.Boolean("$exists", false))),
+			bson.VC.DocumentFromElements(bson.EC.SubDocumentFromElements("gathered."+r, bson.EC.Int64("$lte", now.Add(-cooldown).Unix()))),
+		),
+	)
+	update := bson.NewDocument(
+		bson.EC.SubDocumentFromElements("$inc", bson.EC.Int64("contribution", int64(amount))),
+		bson.EC.SubDocumentFromElements("$set",
+			bson.EC.Int64("gathered."+r, now.Unix()),
+			bson.EC.Int64("lastActive", now.Unix()),
+		),
+	)
+	result, err := players.UpdateOne(ctx, filter, update)
+	if err != nil || result.MatchedCount == 0 {
+		return false, err
+	}
+
+	// Add the gathered Resource to the Server, giving the gathering back to the User if that fails
+	servers := m.database.Collection("servers")
+	filter = bson.NewDocument(bson.EC.String("id", s.ID))
+	update = bson.NewDocument(bson.EC.SubDocumentFromElements("$inc", bson.EC.Int64("resources."+r+".count", int64(amount))))
+	result, err = servers.UpdateOne(ctx, filter, update)
+	if err != nil || result.MatchedCount == 0 {
+		m.releaseGathering(s, u, r, amount, now, cooldown)
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseGathering reverts the gathering claimed by User `u` at `now` when the Resource could not be added to the Server
+func (m *MongoStore) releaseGathering(s *structure.Server, u *structure.User, r string, amount int, now time.Time, cooldown time.Duration) {
+	// A fresh context, as the failed one may have run out
+	ctx, cancel := m.context()
+	defer cancel()
+	players := m.database.Collection("players")
+	filter := bson.NewDocument(
+		bson.EC.String("guild", s.ID),
+		bson.EC.String("id", u.ID),
+		bson.EC.Int64("gathered."+r, now.Unix()),
+	)
+	update := bson.NewDocument(
+		bson.EC.SubDocumentFromElements("$inc", bson.EC.Int64("contribution", -int64(amount))),
+		bson.EC.SubDocumentFromElements("$set", bson.EC.Int64("gathered."+r, now.Add(-cooldown).Unix())),
+	)
+	_, err := players.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+}
+
+// AddServerCard adds a posted Card to the game
+func (m *MongoStore) AddServerCard(s *structure.Server, c *structure.ActiveCard) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(bson.EC.String("id", s.ID))
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$push", bson.EC.Interface("activeCards", c)))
+	_, err := collection.UpdateOne(ctx, filter, replacement)
+	return err
+}
+
+// ClaimServerCard claims Card `c` for User `u`, paying `costs` and receiving `rewards` and `contribution`.
+// The update only happens if the Card has not expired, has claims left, was not claimed by the User before
+// and the Server can afford the costs, reported by the returned bool. A Card allowing no claims is never claimed
+func (m *MongoStore) ClaimServerCard(s *structure.Server, c *structure.ActiveCard, u *structure.User, costs map[string]int, rewards map[string]int, contribution int) (bool, error) {
+	if c.Claims <= 0 {
+		return false, nil
+	}
+	ctx, cancel := m.context()
+	defer cancel()
+	now := time.Now()
+
+	// Claim the Card and pay for it on the Server first, so it can not be claimed twice
+	servers := m.database.Collection("servers")
+	filter := bson.NewDocument(
+		bson.EC.String("id", s.ID),
+		bson.EC.SubDocumentFromElements("activeCards", bson.EC.SubDocumentFromElements("$elemMatch",
+			bson.EC.String("messageID", c.MessageID),
+			bson.EC.SubDocumentFromElements("expires", bson.EC.Int64("$gt", now.Unix())),
+			bson.EC.SubDocumentFromElements("claimedBy", bson.EC.String("$ne", u.ID)),
+			bson.EC.SubDocumentFromElements("claimedBy."+strconv.Itoa(c.Claims-1), bson.EC.Boolean("$exists", false)),
+		)),
+	)
+	changes := map[string]int{}
+	for key, amount := range costs {
+		filter.Append(bson.EC.SubDocumentFromElements("resources."+key+".count", bson.EC.Int64("$gte", int64(amount))))
+		changes[key] -= amount
+	}
+	for key, amount := range rewards {
+		changes[key] += amount
+	}
+	update := bson.NewDocument(bson.EC.SubDocumentFromElements("$push", bson.EC.String("activeCards.$.claimedBy", u.ID)))
+	if len(changes) > 0 {
+		inc := bson.NewDocument()
+		for key, amount := range changes {
+			inc.Append(bson.EC.Int64("resources."+key+".count", int64(amount)))
+		}
+		update.Append(bson.EC.SubDocument("$inc", inc))
+	}
+	result, err := servers.UpdateOne(ctx, filter, update)
+	if err != nil || result.MatchedCount == 0 {
+		return false, err
+	}
+
+	// Add the contribution to the User, giving the claim back to the Server if that fails
+	players := m.database.Collection("players")
+	filter = bson.NewDocument(
+		bson.EC.String("guild", s.ID),
+		bson.EC.String("id", u.ID),
+		bson.EC.Int64("departed", 0),
+	)
+	update = bson.NewDocument(
+		bson.EC.SubDocumentFromElements("$inc", bson.EC.Int64("contribution", int64(contribution))),
+		bson.EC.SubDocumentFromElements("$set", bson.EC.Int64("lastActive", now.Unix())),
+	)
+	result, err = players.UpdateOne(ctx, filter, update)
+	if err != nil || result.MatchedCount == 0 {
+		m.releaseClaim(s, c, u, changes)
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseClaim reverts the claim of Card `c` by User `u` and its Resource `changes` when the contribution could not be added to the User,
+// who may have left meanwhile
+func (m *MongoStore) releaseClaim(s *structure.Server, c *structure.ActiveCard, u *structure.User, changes map[string]int) {
+	// A fresh context, as the failed one may have run out
+	ctx, cancel := m.context()
+	defer cancel()
+	servers := m.database.Collection("servers")
+	filter := bson.NewDocument(
+		bson.EC.String("id", s.ID),
+		bson.EC.String("activeCards.messageID", c.MessageID),
+	)
+	update := bson.NewDocument(bson.EC.SubDocumentFromElements("$pull", bson.EC.String("activeCards.$.claimedBy", u.ID)))
+	if len(changes) > 0 {
+		inc := bson.NewDocument()
+		for key, amount := range changes {
+			inc.Append(bson.EC.Int64("resources."+key+".count", -int64(amount)))
+		}
+		update.Append(bson.EC.SubDocument("$inc", inc))
+	}
+	_, err := servers.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+}
+
+// RemoveExpiredServerCards removes every Card that expired before `t` from the game
+func (m *MongoStore) RemoveExpiredServerCards(s *structure.Server, t time.Time) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(bson.EC.String("id", s.ID))
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$pull", bson.EC.SubDocumentFromElements("activeCards",
+		bson.EC.SubDocumentFromElements("expires", bson.EC.Int64("$lte", t.Unix())),
+	)))
+	_, err := collection.UpdateOne(ctx, filter, replacement)
+	return err
+}
+
+// AddServerResources adds `amounts` to the Resources of given Server
+func (m *MongoStore) AddServerResources(s *structure.Server, amounts map[string]int) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(bson.EC.String("id", s.ID))
+	inc := bson.NewDocument()
+	for key, amount := range amounts {
+		inc.Append(bson.EC.Int64("resources."+key+".count", int64(amount)))
+	}
+	replacement := bson.NewDocument(bson.EC.SubDocument("$inc", inc))
+	_, err := collection.UpdateOne(ctx, filter, replacement)
+	return err
+}
+
+// ResetServerProgress removes every User, Card and Resource of given Server, keeping its Discord structure and settings
+func (m *MongoStore) ResetServerProgress(s *structure.Server) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(bson.EC.String("id", s.ID))
+	set := bson.NewDocument(bson.EC.ArrayFromElements("activeCards"))
+	for key := range s.Resources {
+		set.Append(bson.EC.Int64("resources."+key+".count", 0))
+	}
+	replacement := bson.NewDocument(bson.EC.SubDocument("$set", set))
+	_, err := collection.UpdateOne(ctx, filter, replacement)
+	if err != nil {
+		return err
+	}
+
+	// Remove Users
+	players := m.database.Collection("players")
+	_, err = players.DeleteMany(ctx, bson.NewDocument(bson.EC.String("guild", s.ID)))
+	return err
+}
+
+// ReplaceServer stores given Server in place of the stored one with the same ID, removing its Users
+func (m *MongoStore) ReplaceServer(s *structure.Server) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(bson.EC.String("id", s.ID), bson.EC.Int64("revision", s.Revision))
+	replacement := *s
+	replacement.Version = SchemaVersion
+	replacement.Revision++
+	result, err := collection.ReplaceOne(ctx, filter, &replacement)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return Conflict
+	}
+	s.Version = replacement.Version
+	s.Revision = replacement.Revision
+
+	// Remove Users
+	players := m.database.Collection("players")
+	_, err = players.DeleteMany(ctx, bson.NewDocument(bson.EC.String("guild", s.ID)))
+	return err
+}
+
+// DeleteServer removes a Server object
+func (m *MongoStore) DeleteServer(s *structure.Server) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("servers")
+	filter := bson.NewDocument(bson.EC.String("id", s.ID))
+	_, err := collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	// Remove Users
+	players := m.database.Collection("players")
+	_, err = players.DeleteMany(ctx, bson.NewDocument(bson.EC.String("guild", s.ID)))
+	return err
+}
+
+// GetDueJobs returns every Job scheduled to run before `t`
+func (m *MongoStore) GetDueJobs(t time.Time) ([]*structure.Job, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("jobs")
+	filter := bson.NewDocument(bson.EC.SubDocumentFromElements("next", bson.EC.Int64("$lte", t.Unix())))
+	cursor, err := collection.Find(ctx, filter, findopt.Sort(bson.NewDocument(bson.EC.Int32("next", 1))))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	jobs := []*structure.Job{}
+	for cursor.Next(ctx) {
+		job := &structure.Job{}
+		err = cursor.Decode(job)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, cursor.Err()
+}
+
+// ScheduleJob creates Job `j` or updates the interval of an already scheduled one
+func (m *MongoStore) ScheduleJob(j *structure.Job) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("jobs")
+	filter := bson.NewDocument(bson.EC.String("server", j.Server), bson.EC.String("name", j.Name))
+	replacement := bson.NewDocument(
+		bson.EC.SubDocumentFromElements("$set", bson.EC.Int64("interval", int64(j.Interval))),
+		bson.EC.SubDocumentFromElements("$setOnInsert", bson.EC.Int64("next", j.Next)),
+	)
+	_, err := collection.UpdateOne(ctx, filter, replacement, updateopt.Upsert(true))
+	return err
+}
+
+// ClaimJob moves Job `j` to run next at `next`.
+// The update only happens if no one else claimed the Job since it was loaded, reported by the returned bool
+func (m *MongoStore) ClaimJob(j *structure.Job, next time.Time) (bool, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("jobs")
+	filter := bson.NewDocument(
+		bson.EC.String("server", j.Server),
+		bson.EC.String("name", j.Name),
+		bson.EC.Int64("next", j.Next),
+	)
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set", bson.EC.Int64("next", next.Unix())))
+	result, err := collection.UpdateOne(ctx, filter, replacement)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// DeleteServerJobs removes every Job of the Server with ID `s`
+func (m *MongoStore) DeleteServerJobs(s string) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("jobs")
+	filter := bson.NewDocument(bson.EC.String("server", s))
+	_, err := collection.DeleteMany(ctx, filter)
+	return err
+}
+
+// ClaimCooldown starts cooldown bucket `key` for `d` if it is not running, otherwise returns the time left on it
+func (m *MongoStore) ClaimCooldown(key string, d time.Duration) (time.Duration, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("cooldowns")
+	now := time.Now()
+	filter := bson.NewDocument(
+		bson.EC.String("key", key),
+		bson.EC.SubDocumentFromElements("expires", bson.EC.Int64("$lte", now.Unix())),
+	)
+	replacement := bson.NewDocument(bson.EC.SubDocumentFromElements("$set", bson.EC.Int64("expires", now.Add(d).Unix())))
+	_, err := collection.UpdateOne(ctx, filter, replacement, updateopt.Upsert(true))
+	if err == nil {
+		return 0, nil
+	}
+
+	// A running bucket makes the upsert collide with its key
+	if !isDuplicateKey(err) {
+		return 0, err
+	}
+	b := bucket{}
+	err = collection.FindOne(ctx, bson.NewDocument(bson.EC.String("key", key))).Decode(&b)
+	if err != nil {
+		return 0, err
+	}
+	left := time.Unix(b.Expires, 0).Sub(now)
+	if left < time.Second {
+		left = time.Second
+	}
+	return left, nil
+}
+
+// ReleaseCooldown stops cooldown bucket `key`
+func (m *MongoStore) ReleaseCooldown(key string) error {
+	ctx, cancel := m.context()
+	defer cancel()
+	collection := m.database.Collection("cooldowns")
+	_, err := collection.DeleteOne(ctx, bson.NewDocument(bson.EC.String("key", key)))
+	return err
+}
+
+// isDuplicateKey reports whether `err` is a MongoDB unique index violation
+func isDuplicateKey(err error) bool {
+	writeErrors, ok := err.(mongo.WriteErrors)
+	if !ok {
+		return false
+	}
+	for _, e := range writeErrors {
+		if e.Code == 11000 {
+			return true
+		}
+	}
+	return false
+}
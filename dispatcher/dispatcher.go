@@ -0,0 +1,168 @@
+package dispatcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/config"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+)
+
+// event is a queued call of an event handler
+type event struct {
+	key string
+	run func()
+}
+
+// queue contains the events of a Discord Guild waiting to be handled, in order
+type queue struct {
+	events []*event
+}
+
+// Stats contains the backpressure metrics of the dispatcher
+type Stats struct {
+	Guilds    int   // Discord Guilds with events waiting or being handled
+	Waiting   int   // events waiting in every queue
+	Longest   int   // events waiting in the longest queue
+	Handled   int64 // events handled since Start
+	Merged    int64 // events merged into a waiting one since Start
+	Dropped   int64 // events dropped because their queue was full since Start
+	Overflown int64 // events queued past the limit because they may not be dropped since Start
+}
+
+var mu sync.Mutex
+var queues = map[string]*queue{}
+var stats Stats
+var stopped bool
+var quit chan struct{}
+var wg sync.WaitGroup
+
+// Start accepts events and reports the backpressure metrics in the background until Stop is called
+func Start() {
+	mu.Lock()
+	stopped = false
+	stats = Stats{}
+	mu.Unlock()
+
+	quit = make(chan struct{})
+	wg.Add(1)
+	go report()
+	logger.Log.Info("Dispatcher started.")
+}
+
+// Stop stops accepting events and waits for the queued ones to be handled
+func Stop() {
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+
+	close(quit)
+	wg.Wait()
+	logger.Log.Info("Dispatcher stopped.")
+}
+
+// Dispatch queues `run` behind the other events of Discord Guild `g`, dropping it if the queue is full.
+// A non-empty `key` replaces the waiting event with the same key instead, for events only the latest of which matters
+func Dispatch(g string, key string, run func()) {
+	dispatch(g, &event{key: key, run: run}, false)
+}
+
+// Force queues `run` behind the other events of Discord Guild `g` even if the queue is full, for events which may not be lost
+func Force(g string, run func()) {
+	dispatch(g, &event{run: run}, true)
+}
+
+// Metrics returns the current backpressure metrics
+func Metrics() Stats {
+	mu.Lock()
+	defer mu.Unlock()
+	result := stats
+	result.Guilds = len(queues)
+	for _, q := range queues {
+		result.Waiting += len(q.events)
+		if len(q.events) > result.Longest {
+			result.Longest = len(q.events)
+		}
+	}
+	return result
+}
+
+func dispatch(g string, e *event, force bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if stopped {
+		return
+	}
+
+	// Merge into a waiting event
+	q, ok := queues[g]
+	if ok && e.key != "" {
+		for _, waiting := range q.events {
+			if waiting.key == e.key {
+				waiting.run = e.run
+				stats.Merged++
+				return
+			}
+		}
+	}
+
+	// Check queue size
+	if ok && len(q.events) >= config.Get().QueueSize {
+		if !force {
+			stats.Dropped++
+			logger.Log.Warning("Event queue of Guild %s is full, dropping an event.", g)
+			return
+		}
+		stats.Overflown++
+	}
+
+	// Queue event, starting a worker for an idle Guild
+	if !ok {
+		q = &queue{}
+		queues[g] = q
+		wg.Add(1)
+		go work(g, q)
+	}
+	q.events = append(q.events, e)
+}
+
+// work handles the events of Discord Guild `g` one by one until its queue is empty
+func work(g string, q *queue) {
+	defer wg.Done()
+	for {
+		mu.Lock()
+		if len(q.events) == 0 {
+			delete(queues, g)
+			mu.Unlock()
+			return
+		}
+		e := q.events[0]
+		q.events[0] = nil
+		q.events = q.events[1:]
+		mu.Unlock()
+
+		e.run()
+
+		mu.Lock()
+		stats.Handled++
+		mu.Unlock()
+	}
+}
+
+func report() {
+	defer wg.Done()
+
+	ticker := time.NewTicker(time.Duration(config.Get().QueueReport) * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+
+		current := Metrics()
+		logger.Log.Info("Event queues: %d guilds, %d waiting (longest %d), %d handled, %d merged, %d dropped, %d overflown.",
+			current.Guilds, current.Waiting, current.Longest, current.Handled, current.Merged, current.Dropped, current.Overflown)
+	}
+}
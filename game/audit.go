@@ -0,0 +1,62 @@
+package game
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// auditMemberRoleUpdate is the audit log action type of a change to the Roles of a member
+const auditMemberRoleUpdate = 25
+
+// auditWindow is how old the audit log entry of a Role change may be to describe the change being enforced
+const auditWindow = 30 * time.Second
+
+// discordEpoch is the start of Discord snowflake IDs in Unix milliseconds
+const discordEpoch = 1420070400000
+
+// auditLog contains the entries of the audit log of a Discord Guild, newest first
+type auditLog struct {
+	Entries []*auditLogEntry `json:"audit_log_entries"`
+}
+
+// auditLogEntry contains an action of the audit log of a Discord Guild
+type auditLogEntry struct {
+	ID       string `json:"id"`
+	TargetID string `json:"target_id"`
+	UserID   string `json:"user_id"`
+}
+
+// roleChangeActor returns the ID of the member who last changed the Roles of member `user` of guild `guild`,
+// empty if nobody did within auditWindow
+func roleChangeActor(s *discordgo.Session, guild string, user string) (string, error) {
+	endpoint := discordgo.EndpointGuild(guild) + "/audit-logs"
+	body, err := s.RequestWithBucketID("GET", endpoint+"?action_type="+strconv.Itoa(auditMemberRoleUpdate)+"&limit=10", nil, endpoint)
+	if err != nil {
+		return "", err
+	}
+	log := auditLog{}
+	err = json.Unmarshal(body, &log)
+	if err != nil {
+		return "", err
+	}
+
+	// Find the latest change of the member
+	for _, entry := range log.Entries {
+		if entry.TargetID != user {
+			continue
+		}
+		id, err := strconv.ParseInt(entry.ID, 10, 64)
+		if err != nil {
+			return "", err
+		}
+		created := time.Unix(0, ((id>>22)+discordEpoch)*int64(time.Millisecond))
+		if time.Since(created) > auditWindow {
+			return "", nil
+		}
+		return entry.UserID, nil
+	}
+	return "", nil
+}
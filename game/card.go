@@ -0,0 +1,164 @@
+package game
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/builder"
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// SpawnCards posts a random Card of the matching tier in every action Channel without an active Card
+func SpawnCards(server *structure.Server, store db.Store, s *discordgo.Session) {
+	for _, role := range server.Roles {
+		channel, ok := server.Channels["c"+strconv.Itoa(role.Tier)+"action"]
+		if !ok {
+			continue
+		}
+
+		// Check for active Card
+		active := false
+		for _, c := range server.ActiveCards {
+			if c.ChannelID == channel.ID {
+				active = true
+				break
+			}
+		}
+		if active {
+			continue
+		}
+
+		// Pick a Card of the Channel's tier
+		keys := []string{}
+		for key, card := range server.Cards {
+			if card.Tier == role.Tier {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		err := SpawnCard(server, store, s, channel, keys[rand.Intn(len(keys))])
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+	}
+}
+
+// SpawnCard posts Card `key` in Discord Channel `channel`
+func SpawnCard(server *structure.Server, store db.Store, s *discordgo.Session, channel *structure.Channel, key string) error {
+	card := server.Cards[key]
+
+	// Create Card message
+	message := &structure.Message{
+		Title:       card.Title,
+		Description: card.Description,
+		Type:        "card",
+		Icon:        card.Icon,
+		Footer:      "Expires in " + strconv.Itoa(card.Duration) + " minutes. Can be claimed " + strconv.Itoa(card.Claims) + " times.",
+		Fields:      []*structure.Field{},
+	}
+	for _, choice := range card.Choices {
+		message.Fields = append(message.Fields, &structure.Field{
+			Title: server.Actions[choice.Action] + " " + choice.Label,
+			Value: describeChoice(server, choice),
+		})
+	}
+
+	// Send Card
+	m, err := s.ChannelMessageSendEmbed(channel.ID, builder.BuildEmbed(message))
+	if err != nil {
+		return err
+	}
+
+	// Add reactions
+	for _, choice := range card.Choices {
+		err = s.MessageReactionAdd(m.ChannelID, m.ID, server.Actions[choice.Action])
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+	}
+
+	// Update Server object
+	active := &structure.ActiveCard{
+		Card:      key,
+		MessageID: m.ID,
+		ChannelID: m.ChannelID,
+		Expires:   time.Now().Add(time.Duration(card.Duration) * time.Minute).Unix(),
+		Claims:    card.Claims,
+		ClaimedBy: []string{},
+	}
+	server.ActiveCards = append(server.ActiveCards, active)
+	return store.AddServerCard(server, active)
+}
+
+// ExpireCards closes every expired Card of `server`
+func ExpireCards(server *structure.Server, store db.Store, s *discordgo.Session) {
+	now := time.Now()
+	remaining := []*structure.ActiveCard{}
+	for _, c := range server.ActiveCards {
+		if c.Expires > now.Unix() {
+			remaining = append(remaining, c)
+			continue
+		}
+
+		// Close Card message
+		err := s.MessageReactionsRemoveAll(c.ChannelID, c.MessageID)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+		if card, ok := server.Cards[c.Card]; ok {
+			message := &structure.Message{
+				Title:       card.Title,
+				Description: card.Description,
+				Type:        "card",
+				Icon:        card.Icon,
+				Footer:      "This card has expired.",
+			}
+			_, err = s.ChannelMessageEditEmbed(c.ChannelID, c.MessageID, builder.BuildEmbed(message))
+			if err != nil {
+				logger.Log.Error(err.Error())
+			}
+		}
+	}
+
+	// Update Server object
+	if len(remaining) == len(server.ActiveCards) {
+		return
+	}
+	server.ActiveCards = remaining
+	err := store.RemoveExpiredServerCards(server, now)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+}
+
+func describeChoice(server *structure.Server, choice *structure.Choice) string {
+	parts := []string{}
+	if len(choice.Costs) > 0 {
+		parts = append(parts, "Costs: "+describeResources(server, choice.Costs))
+	}
+	if len(choice.Rewards) > 0 {
+		parts = append(parts, "Rewards: "+describeResources(server, choice.Rewards))
+	}
+	parts = append(parts, "Contribution: "+strconv.Itoa(choice.Contribution))
+	return strings.Join(parts, "\n")
+}
+
+func describeResources(server *structure.Server, amounts map[string]int) string {
+	parts := []string{}
+	for key, amount := range amounts {
+		resource, ok := server.Resources[key]
+		if !ok {
+			continue
+		}
+		parts = append(parts, strconv.Itoa(amount)+" <"+resource.Icon+">")
+	}
+	return strings.Join(parts, ", ")
+}
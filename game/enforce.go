@@ -0,0 +1,140 @@
+package game
+
+import (
+	"github.com/Noxdew/Knights-Of-Discord/builder"
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// EnforceRoles reverts manual changes to the game Roles of `member` so they match the stored game Role.
+// A change to a player made by a member holding an override Role is kept and becomes their stored game Role
+func EnforceRoles(server *structure.Server, store db.Store, s *discordgo.Session, member *discordgo.Member) {
+	if member.User.Bot {
+		return
+	}
+
+	// Find game Roles held by member
+	held := []string{}
+	for _, id := range member.Roles {
+		if RoleKey(server, id) != "" {
+			held = append(held, id)
+		}
+	}
+
+	// Find stored game Role
+	user, err := store.GetServerUser(server, member.User.ID)
+	if err != nil && err != db.NotFound {
+		logger.Log.Error(err.Error())
+		return
+	}
+	expected := ""
+	if user != nil {
+		expected = user.Role
+	}
+
+	// Find changes not made by the game
+	unexpected := []string{}
+	found := false
+	for _, id := range held {
+		if id == expected {
+			found = true
+		} else if !builder.RoleChangeExpected(server.ID, member.User.ID, id) {
+			unexpected = append(unexpected, id)
+		}
+	}
+	missing := expected != "" && !found && RoleKey(server, expected) != "" && !builder.RoleChangeExpected(server.ID, member.User.ID, expected)
+	if len(unexpected) == 0 && !missing {
+		return
+	}
+
+	// Accept override
+	if user != nil && changedByOverride(server, s, member.User.ID) {
+		if len(held) == 1 && held[0] != expected {
+			user.Role = held[0]
+			err := store.UpdateServerUserRole(server, user)
+			if err != nil {
+				logger.Log.Error(err.Error())
+				return
+			}
+			adminLog(server, s, "Kept override of <@"+user.ID+"> to **"+server.Roles[RoleKey(server, held[0])].DefaultName+"**.")
+		}
+		return
+	}
+
+	// Revert unauthorized changes
+	for _, id := range unexpected {
+		builder.ExpectRoleChange(server.ID, member.User.ID, id)
+		err := s.GuildMemberRoleRemove(server.ID, member.User.ID, id)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			continue
+		}
+		adminLog(server, s, "Removed **"+server.Roles[RoleKey(server, id)].DefaultName+"** from <@"+member.User.ID+">.")
+	}
+	if missing {
+		builder.ExpectRoleChange(server.ID, member.User.ID, expected)
+		err := s.GuildMemberRoleAdd(server.ID, member.User.ID, expected)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			return
+		}
+		adminLog(server, s, "Gave **"+server.Roles[RoleKey(server, expected)].DefaultName+"** back to <@"+member.User.ID+">.")
+	}
+}
+
+// changedByOverride reports whether the latest Role change of member `user` was made by a member holding an override Role of `server`
+func changedByOverride(server *structure.Server, s *discordgo.Session, user string) bool {
+	if len(server.Overrides) == 0 {
+		return false
+	}
+	actor, err := roleChangeActor(s, server.ID, user)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return false
+	}
+	if actor == "" {
+		return false
+	}
+
+	// Check Roles of the actor
+	member, err := s.State.Member(server.ID, actor)
+	if err != nil {
+		member, err = s.GuildMember(server.ID, actor)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			return false
+		}
+	}
+	for _, id := range member.Roles {
+		for _, o := range server.Overrides {
+			if o == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func adminLog(server *structure.Server, s *discordgo.Session, description string) {
+	logger.Log.Info("Guild %s: %s", server.ID, description)
+	if server.AdminLog == "" {
+		return
+	}
+
+	// Create log message
+	message := &structure.Message{
+		Title:       "Role enforcement",
+		Description: description,
+		Type:        "system",
+		Icon:        "https://cdn.discordapp.com/attachments/512302843437252611/512302951814004752/ac6918be09a389876ee5663d6b08b55a.png",
+		Footer:      "Admin log.",
+	}
+
+	// Send log message
+	_, err := s.ChannelMessageSendEmbed(server.AdminLog, builder.BuildEmbed(message))
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+}
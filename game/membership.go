@@ -0,0 +1,81 @@
+package game
+
+import (
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/builder"
+	"github.com/Noxdew/Knights-Of-Discord/config"
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// DepartUser removes the User with ID `id` who left the Discord Guild from the game, keeping their progress for the rejoin window
+func DepartUser(server *structure.Server, store db.Store, id string) {
+	u, err := store.GetServerUser(server, id)
+	if err != nil {
+		if err != db.NotFound {
+			logger.Log.Error(err.Error())
+		}
+		return
+	}
+
+	// Update User object
+	u.Departed = time.Now().Unix()
+	err = store.DepartServerUser(server, u)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+}
+
+// RejoinUser gives the User with ID `id` back their game Role and progress if they left the Discord Guild within the rejoin window
+func RejoinUser(server *structure.Server, store db.Store, s *discordgo.Session, id string) {
+	u, err := store.GetDepartedServerUser(server, id)
+	if err != nil {
+		if err != db.NotFound {
+			logger.Log.Error(err.Error())
+		}
+		return
+	}
+
+	// Check rejoin window
+	window := time.Duration(config.Get().RejoinWindow) * time.Hour
+	if time.Unix(u.Departed, 0).Add(window).Before(time.Now()) {
+		err = store.ForgetServerUser(server, u)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+		return
+	}
+
+	// Game Role may have been rebuilt while the User was away
+	key := RoleKey(server, u.Role)
+	if key == "" {
+		for k, role := range server.Roles {
+			if role.Threshold <= u.Contribution && (key == "" || role.Tier > server.Roles[key].Tier) {
+				key = k
+			}
+		}
+	}
+	if key == "" {
+		return
+	}
+
+	// Assign Game Role to User
+	builder.ExpectRoleChange(server.ID, u.ID, server.Roles[key].ID)
+	err = s.GuildMemberRoleAdd(server.ID, u.ID, server.Roles[key].ID)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	// Update User object
+	u.Role = server.Roles[key].ID
+	u.Departed = 0
+	u.LastActive = time.Now().Unix()
+	err = store.RejoinServerUser(server, u)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+}
@@ -0,0 +1,36 @@
+package game
+
+import (
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ProduceResources adds the passive production of every Resource to `server`, scaled by its number of players
+func ProduceResources(server *structure.Server, store db.Store, s *discordgo.Session) {
+	players, err := store.CountServerUsers(server)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	amounts := map[string]int{}
+	for key, resource := range server.Resources {
+		if resource.Production > 0 && players > 0 {
+			amounts[key] = resource.Production * players
+		}
+	}
+	if len(amounts) == 0 {
+		return
+	}
+
+	// Update Server object
+	err = store.AddServerResources(server, amounts)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	for key, amount := range amounts {
+		server.Resources[key].Count += amount
+	}
+}
@@ -0,0 +1,128 @@
+package game
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/builder"
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// RoleKey returns the structure key of the game Role with Discord ID `id`
+func RoleKey(server *structure.Server, id string) string {
+	for key, role := range server.Roles {
+		if role.ID == id {
+			return key
+		}
+	}
+	return ""
+}
+
+// UpdateTier promotes User `u` to the highest game Role their contribution allows
+func UpdateTier(server *structure.Server, store db.Store, s *discordgo.Session, u *structure.User) {
+	current := RoleKey(server, u.Role)
+	if current == "" {
+		return
+	}
+
+	// Find highest reached Role
+	target := current
+	for key, role := range server.Roles {
+		if role.Threshold <= u.Contribution && role.Tier > server.Roles[target].Tier {
+			target = key
+		}
+	}
+	if target == current {
+		return
+	}
+
+	// Swap Discord Roles
+	err := swapRole(server, s, u, current, target)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	// Update Server object
+	err = store.UpdateServerUserRole(server, u)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	builder.Announce(server, s, "Promotion!", "<@"+u.ID+"> has been promoted to **"+server.Roles[target].DefaultName+"**!")
+}
+
+// DemoteInactive demotes every User of `server` who has been inactive for longer than the Server's inactivity period
+func DemoteInactive(server *structure.Server, store db.Store, s *discordgo.Session) {
+	if !server.Demotion || server.Inactivity <= 0 {
+		return
+	}
+
+	users, err := store.GetServerUsers(server)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	cutoff := time.Now().Add(-time.Duration(server.Inactivity) * time.Hour)
+	for _, u := range users {
+		if u.LastActive > cutoff.Unix() {
+			continue
+		}
+		current := RoleKey(server, u.Role)
+		if current == "" {
+			continue
+		}
+
+		// Find the Role one tier below
+		target := ""
+		for key, role := range server.Roles {
+			if role.Tier < server.Roles[current].Tier && (target == "" || role.Tier > server.Roles[target].Tier) {
+				target = key
+			}
+		}
+		if target == "" {
+			continue
+		}
+
+		// Swap Discord Roles
+		err := swapRole(server, s, u, current, target)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			continue
+		}
+
+		// Update Server object
+		u.LastActive = time.Now().Unix()
+		err = store.DemoteServerUser(server, u)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			continue
+		}
+
+		builder.Announce(server, s, "Demotion!", "<@"+u.ID+"> has been demoted to **"+server.Roles[target].DefaultName+"** after "+strconv.Itoa(server.Inactivity)+" hours of inactivity.")
+	}
+}
+
+// swapRole moves User `u` from game Role `from` to game Role `to`, leaving them with `from` if either change fails
+func swapRole(server *structure.Server, s *discordgo.Session, u *structure.User, from string, to string) error {
+	builder.ExpectRoleChange(server.ID, u.ID, server.Roles[from].ID, server.Roles[to].ID)
+	err := s.GuildMemberRoleAdd(server.ID, u.ID, server.Roles[to].ID)
+	if err != nil {
+		return err
+	}
+	err = s.GuildMemberRoleRemove(server.ID, u.ID, server.Roles[from].ID)
+	if err != nil {
+		// Roll back the added Role, so the member does not hold two game Roles
+		rollbackErr := s.GuildMemberRoleRemove(server.ID, u.ID, server.Roles[to].ID)
+		if rollbackErr != nil {
+			logger.Log.Error(rollbackErr.Error())
+		}
+		return err
+	}
+	u.Role = server.Roles[to].ID
+	return nil
+}
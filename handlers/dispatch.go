@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/Noxdew/Knights-Of-Discord/dispatcher"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Dispatched returns every event handler of the game, handling the events of each Discord Guild in order through the dispatcher.
+// Guild joins and leaves are never dropped, repeated updates of a Discord Role, Channel or Member are merged.
+// Only ReadyHandler runs outside the queues, as it touches no Guild
+func (h *Handler) Dispatched() []interface{} {
+	return []interface{}{
+		h.ReadyHandler,
+		func(s *discordgo.Session, g *discordgo.GuildCreate) {
+			dispatcher.Force(g.ID, func() { h.ServerJoinHandler(s, g) })
+		},
+		func(s *discordgo.Session, g *discordgo.GuildDelete) {
+			dispatcher.Force(g.ID, func() { h.ServerLeaveHandler(s, g) })
+		},
+		func(s *discordgo.Session, e *discordgo.Event) {
+			// discordgo drops the Guild of messages and reactions, so it is read from the raw event
+			switch event := e.Struct.(type) {
+			case *discordgo.MessageCreate:
+				g := eventGuild(e)
+				dispatcher.Dispatch(queue(g, event.ChannelID), "", func() { h.MessageReceiveHandler(s, event, g) })
+			case *discordgo.MessageReactionAdd:
+				g := eventGuild(e)
+				dispatcher.Dispatch(queue(g, event.ChannelID), "", func() { h.ReactionAddHandler(s, event, g) })
+			}
+		},
+		func(s *discordgo.Session, r *discordgo.GuildRoleUpdate) {
+			dispatcher.Dispatch(r.GuildID, "role/"+r.Role.ID, func() { h.RoleEditHandler(s, r) })
+		},
+		func(s *discordgo.Session, c *discordgo.ChannelUpdate) {
+			dispatcher.Dispatch(c.GuildID, "channel/"+c.ID, func() { h.ChannelEditHandler(s, c) })
+		},
+		func(s *discordgo.Session, c *discordgo.ChannelDelete) {
+			dispatcher.Dispatch(c.GuildID, "", func() { h.ChannelDeleteHandler(s, c) })
+		},
+		func(s *discordgo.Session, r *discordgo.GuildRoleDelete) {
+			dispatcher.Dispatch(r.GuildID, "", func() { h.RoleDeleteHandler(s, r) })
+		},
+		func(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+			dispatcher.Dispatch(m.GuildID, "", func() { h.MemberRemoveHandler(s, m) })
+		},
+		func(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+			dispatcher.Dispatch(m.GuildID, "", func() { h.MemberAddHandler(s, m) })
+		},
+		func(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+			dispatcher.Dispatch(m.GuildID, "member/"+m.User.ID, func() { h.MemberUpdateHandler(s, m) })
+		},
+	}
+}
+
+// eventGuild returns the ID of the Discord Guild raw event `e` happened in, empty for direct messages
+func eventGuild(e *discordgo.Event) string {
+	data := struct {
+		GuildID string `json:"guild_id"`
+	}{}
+	err := json.Unmarshal(e.RawData, &data)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+	return data.GuildID
+}
+
+// queue returns the queue for the events of Channel `c` of Discord Guild `g`, which is the Guild itself,
+// or a queue of its own for a direct message Channel
+func queue(g string, c string) string {
+	if g == "" {
+		return "dm/" + c
+	}
+	return g
+}
@@ -2,123 +2,197 @@ package handlers
 
 import (
 	"strings"
+	"time"
 
 	"github.com/Noxdew/Knights-Of-Discord/builder"
 	"github.com/Noxdew/Knights-Of-Discord/command"
-	"github.com/Noxdew/Knights-Of-Discord/config"
 	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/game"
 	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/scheduler"
 	"github.com/Noxdew/Knights-Of-Discord/structure"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+// Handler contains the Discord event handlers of the game
+type Handler struct {
+	Store db.Store
+}
+
 // ReadyHandler is called when `Ready` event is triggered
-func ReadyHandler(s *discordgo.Session, r *discordgo.Ready) {
+func (h *Handler) ReadyHandler(s *discordgo.Session, r *discordgo.Ready) {
 	s.UpdateStatus(0, "Knights of Discord")
-	structure.DefaultServer.BuildServer()
 	logger.Log.Info("Knights of Discord has successfully started.")
 }
 
 // ServerJoinHandler is called when `GuildCreate` event is triggered
-func ServerJoinHandler(s *discordgo.Session, g *discordgo.GuildCreate) {
-	server, err := db.GetServer(g.Guild.ID)
+func (h *Handler) ServerJoinHandler(s *discordgo.Session, g *discordgo.GuildCreate) {
+	// The Server may be built or restored below
+	defer command.ForgetPrefix(g.Guild.ID)
+
+	server, err := h.Store.GetServer(g.Guild.ID)
 	if err != nil && err != db.NotFound {
 		logger.Log.Error(err.Error())
 	} else if err == db.NotFound {
-		builder.BuildServer(server, s, g.Guild)
-	} else {
-		// Server exists
-	}
-}
-
-// MessageReceiveHandler function called when Message is sent
-func MessageReceiveHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
-	// Ignore bot messages
-	if m.Author.Bot {
-		return
-	}
-
-	// Check for command
-	if strings.HasPrefix(m.Content, config.Get().Prefix) {
-		// Get Server object
-		c, err := s.Channel(m.ChannelID)
+		err = builder.BuildServer(server, h.Store, s, g.Guild)
 		if err != nil {
 			logger.Log.Error(err.Error())
 			return
 		}
-		server, err := db.GetServer(c.GuildID)
+		err = scheduler.Schedule(server, h.Store)
 		if err != nil {
 			logger.Log.Error(err.Error())
-			return
 		}
-
-		// Execute command
-		trigger := strings.Split(m.Content, config.Get().Prefix)[1]
-		for _, cmd := range command.MessageCommands {
-			if cmd.Trigger() == trigger {
-				cmd.Execute(server, s, m)
-				return
+	} else if server.Uninstalled {
+		// Game was uninstalled, keep waiting for the install command
+		if server.Archived != 0 {
+			err = db.Update(h.Store, server, func(server *structure.Server) error {
+				server.Archived = 0
+				return h.Store.UpdateServerArchived(server)
+			})
+			if err != nil {
+				logger.Log.Error(err.Error())
 			}
 		}
-
-		// Fallback for wrong command
-		// Create response message
-		message := &structure.Message{
-			Title:  "Unknown command. Try using `!kod-help` for a list of all game commands.",
-			Type:   "system",
-			Icon:   "https://cdn.discordapp.com/attachments/512302843437252611/512302951814004752/ac6918be09a389876ee5663d6b08b55a.png",
-			Footer: "Command execution feedback.",
+	} else if server.Archived != 0 {
+		// Server was archived after the bot was removed
+		builder.RestoreServer(server, h.Store, s, g.Guild)
+		err = scheduler.Schedule(server, h.Store)
+		if err != nil {
+			logger.Log.Error(err.Error())
 		}
-
-		// Build Embed
-		embed := builder.BuildEmbed(message)
-
-		// Send response
-		_, err = s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	} else if server.Building {
+		// Server build was interrupted
+		err = builder.ResumeBuild(server, h.Store, s, g.Guild)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			return
+		}
+		err = scheduler.Schedule(server, h.Store)
 		if err != nil {
 			logger.Log.Error(err.Error())
 		}
+	} else if server.Playing {
+		// Server exists
+		builder.ReconcileServer(server, h.Store, s, g.Guild)
+		err = scheduler.Schedule(server, h.Store)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+	} else if server.Paused {
+		// Server is paused, repair its structure without running the game
+		builder.ReconcileServer(server, h.Store, s, g.Guild)
 	}
 }
 
-// ReactionAddHandler function called when a Reaction is sent
-func ReactionAddHandler(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
-	// Check author for bot
-	u, err := s.User(r.UserID)
+// ServerLeaveHandler is called when `GuildDelete` event is triggered
+func (h *Handler) ServerLeaveHandler(s *discordgo.Session, g *discordgo.GuildDelete) {
+	// Ignore Discord outages
+	if g.Unavailable {
+		logger.Log.Info("Guild %s is unavailable.", g.ID)
+		return
+	}
+
+	// Get Server object
+	server, err := h.Store.GetServer(g.ID)
+	if err != nil {
+		if err != db.NotFound {
+			logger.Log.Error(err.Error())
+		}
+		return
+	}
+	if server.Archived != 0 {
+		return
+	}
+
+	// Archive Server
+	err = db.Update(h.Store, server, func(server *structure.Server) error {
+		server.Playing = false
+		server.Archived = time.Now().Unix()
+		return h.Store.UpdateServerArchived(server)
+	})
 	if err != nil {
 		logger.Log.Error(err.Error())
 		return
 	}
-	if u.Bot {
+	err = scheduler.Unschedule(server, h.Store)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+	command.ForgetPrefix(g.ID)
+	logger.Log.Info("Server for Guild %s archived.", g.ID)
+}
+
+// MessageReceiveHandler function called when Message is sent in Discord Guild `g`, empty for direct messages
+func (h *Handler) MessageReceiveHandler(s *discordgo.Session, m *discordgo.MessageCreate, g string) {
+	// Ignore bot and direct messages
+	if m.Author.Bot || g == "" {
+		return
+	}
+
+	// Check for command
+	line, ok := command.Match(h.Store, s, g, m.Content)
+	if !ok {
+		// Check for typed confirmation code
+		command.Answer(h.Store, s, m, g)
 		return
 	}
 
 	// Get Server object
-	c, err := s.Channel(r.ChannelID)
+	server, err := h.Store.GetServer(g)
 	if err != nil {
 		logger.Log.Error(err.Error())
 		return
 	}
-	server, err := db.GetServer(c.GuildID)
+
+	// Execute command
+	command.Route(server, h.Store, s, m, line)
+}
+
+// ReactionAddHandler function called when a Reaction is sent in Discord Guild `g`, empty for direct messages
+func (h *Handler) ReactionAddHandler(s *discordgo.Session, r *discordgo.MessageReactionAdd, g string) {
+	// Reactions in direct messages are not handled
+	if g == "" {
+		return
+	}
+
+	// Check author for bot
+	u, err := s.User(r.UserID)
 	if err != nil {
 		logger.Log.Error(err.Error())
 		return
 	}
+	if u.Bot {
+		return
+	}
 
-	// Call reaction command
+	// Get Server object
+	server, err := h.Store.GetServer(g)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+
+	// Call reaction command, custom emojis are matched by ID and unicode emojis by name
+	emoji := r.Emoji.ID
+	if emoji == "" {
+		emoji = r.Emoji.Name
+	}
 	for _, cmd := range command.ReactionCommands {
-		if cmd.Trigger() == r.Emoji.ID {
-			cmd.Execute(server, s, r)
+		if emojiKey(cmd.Trigger()) == emoji {
+			if cmd.Targets(server, r) && command.Allow(server, h.Store, s, r.ChannelID, emoji, r.UserID, cmd.Cooldown()) {
+				cmd.Execute(server, h.Store, s, r)
+			}
 			return
 		}
 	}
 }
 
 // RoleEditHandler function called when a Discord Role receives an update
-func RoleEditHandler(s *discordgo.Session, r *discordgo.GuildRoleUpdate) {
+func (h *Handler) RoleEditHandler(s *discordgo.Session, r *discordgo.GuildRoleUpdate) {
 	// Get Server object
-	server, err := db.GetServer(r.GuildID)
+	server, err := h.Store.GetServer(r.GuildID)
 	if err != nil && err != db.NotFound {
 		logger.Log.Error(err.Error())
 		return
@@ -126,8 +200,8 @@ func RoleEditHandler(s *discordgo.Session, r *discordgo.GuildRoleUpdate) {
 		return
 	}
 
-	// Check for playing Server
-	if !server.Playing {
+	// Check for maintained Server
+	if !server.Maintained() {
 		return
 	}
 
@@ -147,9 +221,9 @@ func RoleEditHandler(s *discordgo.Session, r *discordgo.GuildRoleUpdate) {
 }
 
 // ChannelEditHandler function called  when a Discord Channel receives an update
-func ChannelEditHandler(s *discordgo.Session, c *discordgo.ChannelUpdate) {
+func (h *Handler) ChannelEditHandler(s *discordgo.Session, c *discordgo.ChannelUpdate) {
 	// Get Server object
-	server, err := db.GetServer(c.GuildID)
+	server, err := h.Store.GetServer(c.GuildID)
 	if err != nil && err != db.NotFound {
 		logger.Log.Error(err.Error())
 		return
@@ -157,8 +231,8 @@ func ChannelEditHandler(s *discordgo.Session, c *discordgo.ChannelUpdate) {
 		return
 	}
 
-	// Check for playing Server
-	if !server.Playing {
+	// Check for maintained Server
+	if !server.Maintained() {
 		return
 	}
 
@@ -218,7 +292,14 @@ func ChannelEditHandler(s *discordgo.Session, c *discordgo.ChannelUpdate) {
 							}
 						} else if channel.Type == "action" {
 							// Game Channel
-							err := s.ChannelPermissionSet(channel.ID, role.ID, "role", server.ActionPerm, (server.BotPerm - server.ActionPerm))
+							err := s.ChannelPermissionSet(channel.ID, role.ID, "role", builder.ActionPerm(server), (server.BotPerm - builder.ActionPerm(server)))
+							if err != nil {
+								logger.Log.Error(err.Error())
+								return
+							}
+						} else if channel.Type == "announcement" {
+							// Announcement Channel
+							err := s.ChannelPermissionSet(channel.ID, role.ID, "role", server.AnnouncePerm, (server.BotPerm - server.AnnouncePerm))
 							if err != nil {
 								logger.Log.Error(err.Error())
 								return
@@ -231,3 +312,136 @@ func ChannelEditHandler(s *discordgo.Session, c *discordgo.ChannelUpdate) {
 		}
 	}
 }
+
+// ChannelDeleteHandler function called when a Discord Channel is deleted
+func (h *Handler) ChannelDeleteHandler(s *discordgo.Session, c *discordgo.ChannelDelete) {
+	// Get Server object
+	server, err := h.Store.GetServer(c.GuildID)
+	if err != nil && err != db.NotFound {
+		logger.Log.Error(err.Error())
+		return
+	} else if err == db.NotFound {
+		return
+	}
+
+	// Check for maintained Server
+	if !server.Maintained() {
+		return
+	}
+
+	// Check Discord Category
+	if c.ID == server.Category.ID {
+		g, err := s.Guild(server.ID)
+		if err != nil {
+			logger.Log.Error(err.Error())
+			return
+		}
+		builder.RebuildCategory(server, h.Store, s, g)
+		return
+	}
+
+	// Check Discord Channel
+	for _, channel := range server.Channels {
+		if channel.ID == c.ID {
+			g, err := s.Guild(server.ID)
+			if err != nil {
+				logger.Log.Error(err.Error())
+				return
+			}
+			builder.RebuildChannel(server, h.Store, s, g, channel)
+			return
+		}
+	}
+}
+
+// RoleDeleteHandler function called when a Discord Role is deleted
+func (h *Handler) RoleDeleteHandler(s *discordgo.Session, r *discordgo.GuildRoleDelete) {
+	// Get Server object
+	server, err := h.Store.GetServer(r.GuildID)
+	if err != nil && err != db.NotFound {
+		logger.Log.Error(err.Error())
+		return
+	} else if err == db.NotFound {
+		return
+	}
+
+	// Check for maintained Server
+	if !server.Maintained() {
+		return
+	}
+
+	// Check Discord Role
+	for _, role := range server.Roles {
+		if role.ID == r.RoleID {
+			g, err := s.Guild(server.ID)
+			if err != nil {
+				logger.Log.Error(err.Error())
+				return
+			}
+			builder.RebuildRole(server, h.Store, s, g, role)
+			return
+		}
+	}
+}
+
+// MemberRemoveHandler is called when `GuildMemberRemove` event is triggered
+func (h *Handler) MemberRemoveHandler(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+	// Get Server object
+	server, err := h.Store.GetServer(m.GuildID)
+	if err != nil && err != db.NotFound {
+		logger.Log.Error(err.Error())
+		return
+	} else if err == db.NotFound {
+		return
+	}
+
+	// Check for maintained Server
+	if !server.Maintained() {
+		return
+	}
+
+	game.DepartUser(server, h.Store, m.User.ID)
+}
+
+// MemberAddHandler is called when `GuildMemberAdd` event is triggered
+func (h *Handler) MemberAddHandler(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+	// Get Server object
+	server, err := h.Store.GetServer(m.GuildID)
+	if err != nil && err != db.NotFound {
+		logger.Log.Error(err.Error())
+		return
+	} else if err == db.NotFound {
+		return
+	}
+
+	// Check for maintained Server
+	if !server.Maintained() {
+		return
+	}
+
+	game.RejoinUser(server, h.Store, s, m.User.ID)
+}
+
+// MemberUpdateHandler is called when `GuildMemberUpdate` event is triggered
+func (h *Handler) MemberUpdateHandler(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+	// Get Server object
+	server, err := h.Store.GetServer(m.GuildID)
+	if err != nil && err != db.NotFound {
+		logger.Log.Error(err.Error())
+		return
+	} else if err == db.NotFound {
+		return
+	}
+
+	// Check for maintained Server
+	if !server.Maintained() {
+		return
+	}
+
+	game.EnforceRoles(server, h.Store, s, m.Member)
+}
+
+// emojiKey returns the ID of a custom emoji action written as `name:id`, or the unicode emoji action itself
+func emojiKey(action string) string {
+	return action[strings.LastIndex(action, ":")+1:]
+}
@@ -1,18 +1,30 @@
 package main
 
 import (
+	"flag"
+
 	"github.com/Noxdew/Knights-Of-Discord/bot"
 	"github.com/Noxdew/Knights-Of-Discord/config"
 	"github.com/Noxdew/Knights-Of-Discord/logger"
 )
 
 func main() {
+	migrate := flag.Bool("migrate", false, "upgrade every stored server to the current schema version and exit")
+	dryRun := flag.Bool("dry-run", false, "with -migrate, only list the pending migrations")
+	flag.Parse()
+
 	// Setup the logger
 	logger.Init()
 
 	// Read the config file
 	config.Load()
 
+	// Upgrade stored servers
+	if *migrate {
+		bot.Migrate(*dryRun)
+		return
+	}
+
 	// Start the game
 	bot.Start()
 }
@@ -0,0 +1,242 @@
+package scheduler
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/Noxdew/Knights-Of-Discord/builder"
+	"github.com/Noxdew/Knights-Of-Discord/config"
+	"github.com/Noxdew/Knights-Of-Discord/db"
+	"github.com/Noxdew/Knights-Of-Discord/dispatcher"
+	"github.com/Noxdew/Knights-Of-Discord/game"
+	"github.com/Noxdew/Knights-Of-Discord/logger"
+	"github.com/Noxdew/Knights-Of-Discord/structure"
+	"github.com/bwmarrin/discordgo"
+)
+
+// pollInterval is the time between two checks for due Jobs
+const pollInterval = 5 * time.Second
+
+// purgeInterval is the time between two checks for archived Servers past their grace period
+const purgeInterval = time.Hour
+
+// rolloutInterval is the time between two batches of Servers receiving changes of the game definition
+const rolloutInterval = time.Minute
+
+// Task is a periodic game task run for a single Server
+type Task func(*structure.Server, db.Store, *discordgo.Session)
+
+// Tasks contains every periodic game task by Job name
+var Tasks = map[string]Task{
+	"cards": func(server *structure.Server, store db.Store, s *discordgo.Session) {
+		game.ExpireCards(server, store, s)
+		game.SpawnCards(server, store, s)
+	},
+	"production": game.ProduceResources,
+	"demotion":   game.DemoteInactive,
+}
+
+var quit chan struct{}
+var wg sync.WaitGroup
+
+// Start queues due Jobs, purges and rollouts with the dispatcher in the background until Stop is called
+func Start(store db.Store, s *discordgo.Session) {
+	quit = make(chan struct{})
+	wg.Add(1)
+	go run(store, s)
+	logger.Log.Info("Scheduler started.")
+}
+
+// Stop stops checking for due Jobs, the queued ones are finished by the dispatcher
+func Stop() {
+	close(quit)
+	wg.Wait()
+	logger.Log.Info("Scheduler stopped.")
+}
+
+// Schedule creates every Job of `server`, spreading their first run over their interval
+func Schedule(server *structure.Server, store db.Store) error {
+	for name, interval := range structure.DefaultServer.Schedule {
+		if _, ok := Tasks[name]; !ok || interval <= 0 {
+			continue
+		}
+		err := store.ScheduleJob(&structure.Job{
+			Server:   server.ID,
+			Name:     name,
+			Interval: interval,
+			Next:     time.Now().Add(offset(server.ID+name, interval)).Unix(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unschedule removes every Job of `server`
+func Unschedule(server *structure.Server, store db.Store) error {
+	return store.DeleteServerJobs(server.ID)
+}
+
+func run(store db.Store, s *discordgo.Session) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	purgeTicker := time.NewTicker(purgeInterval)
+	defer purgeTicker.Stop()
+	rolloutTicker := time.NewTicker(rolloutInterval)
+	defer rolloutTicker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-purgeTicker.C:
+			purge(store)
+			continue
+		case <-rolloutTicker.C:
+			rollout(store, s)
+			continue
+		case <-ticker.C:
+		}
+
+		jobs, err := store.GetDueJobs(time.Now())
+		if err != nil {
+			logger.Log.Error(err.Error())
+			continue
+		}
+		// Jobs run behind the events of their Guild, a Job still waiting is not queued twice
+		for _, job := range jobs {
+			job := job
+			dispatcher.Dispatch(job.Server, "job/"+job.Name, func() { runJob(job, store, s) })
+		}
+	}
+}
+
+func runJob(job *structure.Job, store db.Store, s *discordgo.Session) {
+	task, ok := Tasks[job.Name]
+	if !ok {
+		return
+	}
+
+	// Claim Job
+	claimed, err := store.ClaimJob(job, time.Now().Add(time.Duration(job.Interval)*time.Second))
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	// Get Server object, removing the Jobs of a game that is gone
+	server, err := store.GetServer(job.Server)
+	if err != nil && err != db.NotFound {
+		logger.Log.Error(err.Error())
+		return
+	} else if err == db.NotFound || server.Archived != 0 || server.Uninstalled {
+		err = store.DeleteServerJobs(job.Server)
+		if err != nil {
+			logger.Log.Error(err.Error())
+		}
+		return
+	}
+
+	// Skip the run while the game is paused, being built or being resumed
+	if !server.Playing {
+		return
+	}
+
+	task(server, store, s)
+}
+
+// purge deletes every Server archived for longer than the configured grace period and the kept progress of
+// every User who left their Guild longer than the rejoin window ago.
+// Each Server purge is queued behind the events of its Guild, so a Server restored meanwhile is kept
+func purge(store db.Store) {
+	// Purge departed Users
+	window := time.Duration(config.Get().RejoinWindow) * time.Hour
+	err := store.PurgeDepartedUsers(time.Now().Add(-window))
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+
+	// Purge archived Servers
+	grace := time.Duration(config.Get().ArchiveGrace) * time.Hour
+	before := time.Now().Add(-grace)
+	ids, err := store.GetArchivedServers(before)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	for _, id := range ids {
+		id := id
+		dispatcher.Dispatch(id, "purge", func() {
+			// Reload Server, which may have been restored while queued
+			server, err := store.GetServer(id)
+			if err != nil {
+				if err != db.NotFound {
+					logger.Log.Error(err.Error())
+				}
+				return
+			}
+			if server.Archived == 0 || server.Archived > before.Unix() {
+				return
+			}
+
+			err = store.DeleteServerJobs(id)
+			if err != nil {
+				logger.Log.Error(err.Error())
+				return
+			}
+			err = store.DeleteServer(server)
+			if err != nil {
+				logger.Log.Error(err.Error())
+				return
+			}
+			logger.Log.Info("Archived Server for Guild %s purged.", id)
+		})
+	}
+}
+
+// rollout applies changes of the game definition to the next batch of running Servers built from an older one.
+// Each rollout is queued behind the events of its Guild, a rollout still waiting is not queued twice
+func rollout(store db.Store, s *discordgo.Session) {
+	fingerprint := structure.DefaultServer.Fingerprint()
+	ids, err := store.GetRolloutServers(fingerprint, config.Get().RolloutBatch)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	for _, id := range ids {
+		id := id
+		dispatcher.Dispatch(id, "rollout", func() {
+			// Reload Server, which may have been rolled out or stopped while queued
+			server, err := store.GetServer(id)
+			if err != nil {
+				logger.Log.Error(err.Error())
+				return
+			}
+			if !server.Playing || server.Structure == fingerprint {
+				return
+			}
+			g, err := s.State.Guild(id)
+			if err != nil {
+				g, err = s.Guild(id)
+				if err != nil {
+					logger.Log.Error(err.Error())
+					return
+				}
+			}
+			builder.RolloutServer(server, store, s, g)
+		})
+	}
+}
+
+// offset returns a stable delay within `interval` seconds for `key`, so Jobs of different Servers do not run at the same time
+func offset(key string, interval int) time.Duration {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return time.Duration(h.Sum32()%uint32(interval)) * time.Second
+}
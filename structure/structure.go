@@ -1,7 +1,10 @@
 package structure
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 
 	"github.com/Noxdew/Knights-Of-Discord/logger"
@@ -10,40 +13,114 @@ import (
 // Server contains game information for a Discord Guild
 type Server struct {
 	ID           string               `json:"-" bson:"id"`
+	Version      int                  `json:"-" bson:"version"`
+	Revision     int64                `json:"-" bson:"revision"`
+	Structure    string               `json:"-" bson:"structure"`
+	RolloutTried int64                `json:"-" bson:"rolloutTried"`
 	Playing      bool                 `json:"-" bson:"playing"`
+	Building     bool                 `json:"-" bson:"building"`
+	Paused       bool                 `json:"-" bson:"paused"`
+	Archived     int64                `json:"-" bson:"archived"`
+	Uninstalled  bool                 `json:"-" bson:"uninstalled"`
 	Resources    map[string]*Resource `json:"resources" bson:"resources"`
 	BotPerm      int                  `json:"botPerm" bson:"-"`
 	SocialPerm   int                  `json:"socialPerm" bson:"-"`
 	ActionPerm   int                  `json:"actionPerm" bson:"-"`
+	AnnouncePerm int                  `json:"announcementPerm" bson:"-"`
 	RolePerm     int                  `json:"rolePerm" bson:"-"`
+	Inactivity   int                  `json:"inactivity" bson:"-"`
+	Schedule     map[string]int       `json:"schedule" bson:"-"`
+	Demotion     bool                 `json:"-" bson:"demotion"`
+	Prefix       string               `json:"-" bson:"prefix"`
+	Overrides    []string             `json:"-" bson:"overrides"`
+	AdminLog     string               `json:"-" bson:"adminLog"`
 	EveryoneRole string               `json:"-" bson:"everyoneRole"`
 	Roles        map[string]*Role     `json:"roles" bson:"roles"`
 	Category     *Category            `json:"category" bson:"category"`
 	Channels     map[string]*Channel  `json:"channels" bson:"channels"`
 	Messages     map[string]*Message  `json:"messages" bson:"messages"`
 	Actions      map[string]string    `json:"actions" bson:"-"`
-	Users        []*User              `json:"-" bson:"users"`
+	Cards        map[string]*Card     `json:"cards" bson:"-"`
+	ActiveCards  []*ActiveCard        `json:"-" bson:"activeCards"`
 }
 
-// BuildServer creates a new Server object to store Discord Guild information
-func (s *Server) BuildServer() {
+// BuildServer creates a new Server object to store Discord Guild information, failing for an invalid game definition
+func (s *Server) BuildServer() error {
 	file, err := ioutil.ReadFile("structure.json")
 	if err != nil {
-		logger.Log.Error(err.Error())
-		return
+		return err
 	}
 	err = json.Unmarshal(file, s)
+	if err != nil {
+		return err
+	}
+
+	// Validate Cards
+	for key, card := range s.Cards {
+		if card.Claims <= 0 {
+			return errors.New("card " + key + " must allow at least one claim")
+		}
+	}
+	return nil
+}
+
+// Copy returns a deep copy of the game definition of the Server object
+func (s *Server) Copy() Server {
+	server := Server{}
+	data, err := json.Marshal(s)
 	if err != nil {
 		logger.Log.Error(err.Error())
-		return
+		return server
 	}
+	err = json.Unmarshal(data, &server)
+	if err != nil {
+		logger.Log.Error(err.Error())
+	}
+	return server
+}
+
+// Maintained reports whether the Discord structure of the game is kept in shape, which it also is while the game is paused
+func (s *Server) Maintained() bool {
+	return s.Playing || s.Paused
+}
+
+// Fingerprint returns a hash of the Resources, permissions and Discord structure of the game definition, changing whenever they do
+func (s *Server) Fingerprint() string {
+	definition := struct {
+		Resources    map[string]*Resource
+		BotPerm      int
+		SocialPerm   int
+		ActionPerm   int
+		AnnouncePerm int
+		RolePerm     int
+		Roles        map[string]*Role
+		Category     *Category
+		Channels     map[string]*Channel
+		Messages     map[string]*Message
+	}{s.Resources, s.BotPerm, s.SocialPerm, s.ActionPerm, s.AnnouncePerm, s.RolePerm, s.Roles, s.Category, s.Channels, s.Messages}
+	data, err := json.Marshal(definition)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return ""
+	}
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
 }
 
 // Resource contains game information for a Server Resource
 type Resource struct {
-	Name  string `json:"name" bson:"-"`
-	Count int    `json:"count" bson:"count"`
-	Icon  string `json:"icon" bson:"-"`
+	Name       string     `json:"name" bson:"-"`
+	Count      int        `json:"count" bson:"count"`
+	Icon       string     `json:"icon" bson:"-"`
+	Production int        `json:"production" bson:"-"`
+	Gathering  *Gathering `json:"gathering" bson:"-"`
+}
+
+// Gathering contains game information for gathering a Server Resource
+type Gathering struct {
+	Trigger  string         `json:"trigger" bson:"-"`
+	Cooldown int            `json:"cooldown" bson:"-"`
+	Yield    map[string]int `json:"yield" bson:"-"`
 }
 
 // Role contains game information for a Discord Role
@@ -52,6 +129,7 @@ type Role struct {
 	DefaultName string `json:"defaultName" bson:"-"`
 	Mentionable bool   `json:"mentionable" bson:"-"`
 	Hoist       bool   `json:"hoist" bson:"-"`
+	Threshold   int    `json:"threshold" bson:"-"`
 	Tier        int    `json:"tier" bson:"-"`
 }
 
@@ -89,11 +167,54 @@ type Field struct {
 	Value string `json:"value" bson:"-"`
 }
 
-// User contains game information for a Discord User
+// Card contains game information for a game card
+type Card struct {
+	Title       string    `json:"title" bson:"-"`
+	Description string    `json:"description" bson:"-"`
+	Icon        string    `json:"icon" bson:"-"`
+	Tier        int       `json:"tier" bson:"-"`
+	Duration    int       `json:"duration" bson:"-"`
+	Claims      int       `json:"claims" bson:"-"`
+	Choices     []*Choice `json:"choices" bson:"-"`
+}
+
+// Choice contains game information for a reaction choice of a Card
+type Choice struct {
+	Action       string         `json:"action" bson:"-"`
+	Label        string         `json:"label" bson:"-"`
+	Costs        map[string]int `json:"costs" bson:"-"`
+	Rewards      map[string]int `json:"rewards" bson:"-"`
+	Contribution int            `json:"contribution" bson:"-"`
+}
+
+// ActiveCard contains game information for a Card posted in a Discord Channel, times are stored as Unix time
+type ActiveCard struct {
+	Card      string   `json:"-" bson:"card"`
+	MessageID string   `json:"-" bson:"messageID"`
+	ChannelID string   `json:"-" bson:"channelID"`
+	Expires   int64    `json:"-" bson:"expires"`
+	Claims    int      `json:"-" bson:"claims"`
+	ClaimedBy []string `json:"-" bson:"claimedBy"`
+}
+
+// User contains game information for a Discord User of a Guild, times are stored as Unix time.
+// Users who left the Guild keep their progress with a non-zero Departed time
 type User struct {
-	ID           string `json:"-" bson:"id"`
-	Role         string `json:"-" bson:"role"`
-	Contribution int    `json:"-" bson:"contribution"`
+	Guild        string           `json:"-" bson:"guild"`
+	ID           string           `json:"-" bson:"id"`
+	Role         string           `json:"-" bson:"role"`
+	Contribution int              `json:"-" bson:"contribution"`
+	Gathered     map[string]int64 `json:"-" bson:"gathered"`
+	LastActive   int64            `json:"-" bson:"lastActive"`
+	Departed     int64            `json:"-" bson:"departed"`
+}
+
+// Job contains scheduling information for a periodic game task of a Server, times are stored as Unix time
+type Job struct {
+	Server   string `json:"-" bson:"server"`
+	Name     string `json:"-" bson:"name"`
+	Interval int    `json:"-" bson:"interval"`
+	Next     int64  `json:"-" bson:"next"`
 }
 
 // DefaultServer object